@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+// ErrCapabilityNotFound occurs when the provider's capability_name does not match any capability of the application
+type ErrCapabilityNotFound struct {
+	Name string
+	// Available contains the sorted names of the application's capabilities
+	Available []string
+}
+
+func (e *ErrCapabilityNotFound) Error() string {
+	available := "(none)"
+	if len(e.Available) > 0 {
+		available = strings.Join(e.Available, ", ")
+	}
+	return fmt.Sprintf("capability_name (%s) does not match any capability of the application (available capabilities: %s)", e.Name, available)
+}
+
+// findCapability retrieves the capability named capabilityName from the application's run config
+// If there is no matching capability, this returns ErrCapabilityNotFound listing the available capabilities
+func findCapability(runConfig *types.RunConfig, capabilityName string) (*types.CapabilityConfig, error) {
+	available := make([]string, 0)
+	if runConfig != nil {
+		for i, cur := range runConfig.Capabilities {
+			if cur.Name == capabilityName {
+				return &runConfig.Capabilities[i], nil
+			}
+			available = append(available, cur.Name)
+		}
+	}
+	sort.Strings(available)
+	return nil, &ErrCapabilityNotFound{Name: capabilityName, Available: available}
+}
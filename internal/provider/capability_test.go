@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+func TestFindCapability(t *testing.T) {
+	runConfig := &types.RunConfig{
+		WorkspaceConfig: types.WorkspaceConfig{
+			Capabilities: []types.CapabilityConfig{
+				{Name: "secrets", Source: "nullstone/aws-secrets"},
+				{Name: "bucket", Source: "nullstone/aws-s3-access"},
+			},
+		},
+	}
+
+	t.Run("matching capability", func(t *testing.T) {
+		got, err := findCapability(runConfig, "bucket")
+		require.NoError(t, err)
+		assert.Equal(t, "nullstone/aws-s3-access", got.Source)
+	})
+
+	t.Run("unknown capability lists available capabilities", func(t *testing.T) {
+		_, err := findCapability(runConfig, "buckets")
+		var notFound *ErrCapabilityNotFound
+		require.ErrorAs(t, err, &notFound)
+		assert.Equal(t, []string{"bucket", "secrets"}, notFound.Available)
+		assert.Equal(t, "capability_name (buckets) does not match any capability of the application (available capabilities: bucket, secrets)", err.Error())
+	})
+
+	t.Run("application without capabilities", func(t *testing.T) {
+		_, err := findCapability(&types.RunConfig{}, "bucket")
+		assert.EqualError(t, err, "capability_name (bucket) does not match any capability of the application (available capabilities: (none))")
+	})
+}
+
+func TestConnectionsFromRunConfig(t *testing.T) {
+	runConfig := &types.RunConfig{
+		WorkspaceConfig: types.WorkspaceConfig{
+			Connections: types.Connections{"cluster": {}},
+			Capabilities: []types.CapabilityConfig{
+				{Name: "bucket", Connections: types.Connections{"s3": {}}},
+			},
+		},
+	}
+
+	got, err := connectionsFromRunConfig(runConfig, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cluster"}, sortedConnectionNames(got))
+
+	got, err = connectionsFromRunConfig(runConfig, "bucket")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s3"}, sortedConnectionNames(got))
+
+	_, err = connectionsFromRunConfig(runConfig, "missing")
+	assert.ErrorAs(t, err, new(*ErrCapabilityNotFound))
+
+	got, err = connectionsFromRunConfig(nil, "missing")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+	"gopkg.in/nullstone-io/nullstone.v0/workspaces"
+)
+
+// connectionGraphNode is a workspace reached by walking connections from the current workspace
+type connectionGraphNode struct {
+	Workspace types.WorkspaceTarget
+	StackName string
+	BlockName string
+	EnvName   string
+	Module    string
+	// Contract is the contract of the connection that first reached this workspace
+	// This is empty for the current workspace
+	Contract string
+	Depth    int
+	// Error describes why the workspace or its connections could not be retrieved
+	// If set, the connections of this workspace were not followed
+	Error string
+}
+
+// Name is a short, human-readable name for the node used in messages
+func (n connectionGraphNode) Name() string {
+	if n.BlockName != "" {
+		return n.BlockName
+	}
+	return n.Workspace.Id()
+}
+
+// connectionGraphEdge is a connection from one workspace to another
+// From and To are workspace ids
+type connectionGraphEdge struct {
+	From string
+	To   string
+	Name string
+}
+
+type connectionGraph struct {
+	Nodes []connectionGraphNode
+	Edges []connectionGraphEdge
+	// Truncated is true if any connections were not followed because of the depth limit
+	Truncated bool
+}
+
+// buildConnectionGraph walks every connection from root breadth-first, stopping at maxDepth hops
+// Each workspace is visited once; a connection to a visited workspace is recorded as an edge, but not followed again
+// The connections of root are scoped to capabilityName and overridden by localConnections like ns_connection
+// The connections of every other workspace come from its latest run config
+// The run configs of each level of the graph are retrieved in parallel
+// Failing to retrieve root is an error; any other workspace that cannot be retrieved is recorded with an Error and not followed
+func buildConnectionGraph(ctx context.Context, runConfigs *runConfigResolver, root types.WorkspaceTarget, capabilityName string, localConnections workspaces.ManifestConnections, maxDepth int) (*connectionGraph, error) {
+	type queued struct {
+		Workspace types.WorkspaceTarget
+		Contract  string
+		Depth     int
+	}
+
+	graph := &connectionGraph{}
+	visited := map[string]bool{root.Id(): true}
+	queue := []queued{{Workspace: root}}
+	level := 0
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur.Depth != level {
+			// The queue only contains this level, retrieve its run configs in parallel before walking it
+			level = cur.Depth
+			targets := []types.WorkspaceTarget{cur.Workspace}
+			for _, next := range queue {
+				targets = append(targets, next.Workspace)
+			}
+			runConfigs.Prefetch(ctx, targets)
+		}
+
+		log.Printf("(buildConnectionGraph) Pulling workspace run config for @ %s", cur.Workspace.Id())
+		workspace, runConfig, err := runConfigs.Get(ctx, cur.Workspace)
+		if err != nil {
+			if cur.Depth == 0 || ctx.Err() != nil {
+				return nil, fmt.Errorf("error retrieving connections for workspace %s: %w", cur.Workspace.Id(), err)
+			}
+			log.Printf("(buildConnectionGraph) Unable to retrieve connections for workspace %s: %s", cur.Workspace.Id(), err)
+			graph.Nodes = append(graph.Nodes, connectionGraphNode{Workspace: cur.Workspace, Contract: cur.Contract, Depth: cur.Depth, Error: err.Error()})
+			continue
+		}
+		node := connectionGraphNode{Workspace: cur.Workspace, Contract: cur.Contract, Depth: cur.Depth}
+		if workspace != nil {
+			node.StackName, node.BlockName, node.EnvName = workspace.StackName, workspace.BlockName, workspace.EnvName
+		}
+		if runConfig != nil {
+			node.Module = runConfig.Source
+		}
+		graph.Nodes = append(graph.Nodes, node)
+
+		var targets map[string]connectionTarget
+		if cur.Depth == 0 {
+			connections, err := connectionsFromRunConfig(runConfig, capabilityName)
+			if err != nil {
+				return nil, err
+			}
+			targets = resolveConnectionTargets(cur.Workspace, connections, localConnections)
+		} else {
+			connections, _ := connectionsFromRunConfig(runConfig, "")
+			targets = resolveConnectionTargets(cur.Workspace, connections, nil)
+		}
+		if cur.Depth >= maxDepth {
+			if len(targets) > 0 {
+				log.Printf("(buildConnectionGraph) Not following connections of %s: reached max depth (%d)", cur.Workspace.Id(), maxDepth)
+				graph.Truncated = true
+			}
+			continue
+		}
+
+		names := make([]string, 0, len(targets))
+		for name := range targets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			target := targets[name]
+			graph.Edges = append(graph.Edges, connectionGraphEdge{From: cur.Workspace.Id(), To: target.Workspace.Id(), Name: name})
+			if visited[target.Workspace.Id()] {
+				continue
+			}
+			visited[target.Workspace.Id()] = true
+			queue = append(queue, queued{Workspace: target.Workspace, Contract: target.Contract, Depth: cur.Depth + 1})
+		}
+	}
+	return graph, nil
+}
+
+// Cycles finds every cycle in the graph reachable from the first node
+// Each cycle is described by the names of its nodes, starting and ending with the same node (e.g. `app -> cluster -> app`)
+func (g *connectionGraph) Cycles() []string {
+	if len(g.Nodes) == 0 {
+		return nil
+	}
+	nodes := map[string]connectionGraphNode{}
+	for _, node := range g.Nodes {
+		nodes[node.Workspace.Id()] = node
+	}
+	adjacent := map[string][]string{}
+	for _, edge := range g.Edges {
+		adjacent[edge.From] = append(adjacent[edge.From], edge.To)
+	}
+
+	cycles := make([]string, 0)
+	done := map[string]bool{}
+	path := make([]string, 0)
+	onPath := map[string]int{}
+	var visit func(id string)
+	visit = func(id string) {
+		onPath[id] = len(path)
+		path = append(path, id)
+		for _, next := range adjacent[id] {
+			if start, ok := onPath[next]; ok {
+				names := make([]string, 0)
+				for _, cur := range append(path[start:], next) {
+					names = append(names, nodes[cur].Name())
+				}
+				cycles = append(cycles, strings.Join(names, " -> "))
+			} else if !done[next] {
+				visit(next)
+			}
+		}
+		path = path[:len(path)-1]
+		delete(onPath, id)
+		done[id] = true
+	}
+	visit(g.Nodes[0].Workspace.Id())
+	return cycles
+}
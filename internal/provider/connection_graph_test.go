@@ -0,0 +1,195 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/nullstone-io/module/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+	"gopkg.in/nullstone-io/nullstone.v0/workspaces"
+)
+
+// mockConnectionGraph sets up the following workspaces (all in stack 100, env 102):
+// app (101) => cluster (103), postgres (104)
+// cluster (103) => network (105)
+// postgres (104) => network (105)
+// network (105) => cluster (103)
+func mockConnectionGraph() ([]types.Workspace, map[string]types.RunConfig) {
+	workspace := func(blockId int64, blockName string) types.Workspace {
+		return types.Workspace{
+			UidCreatedModel: types.UidCreatedModel{Uid: uuid.New()},
+			OrgName:         "org0",
+			StackId:         100,
+			StackName:       "stack0",
+			BlockId:         blockId,
+			BlockName:       blockName,
+			EnvId:           102,
+			EnvName:         "env0",
+		}
+	}
+	connection := func(contract string, blockId int64) types.Connection {
+		return types.Connection{
+			Connection:      config.Connection{Contract: contract},
+			EffectiveTarget: &types.ConnectionTarget{StackId: 100, BlockId: blockId},
+		}
+	}
+	runConfig := func(workspace types.Workspace, source string, connections types.Connections) types.RunConfig {
+		return types.RunConfig{
+			WorkspaceUid:    workspace.Uid,
+			WorkspaceConfig: types.WorkspaceConfig{Source: source, Connections: connections},
+		}
+	}
+
+	app, cluster, postgres, network := workspace(101, "app"), workspace(103, "cluster"), workspace(104, "postgres"), workspace(105, "network")
+	runConfigs := map[string]types.RunConfig{
+		app.Uid.String(): runConfig(app, "nullstone/aws-fargate-service", types.Connections{
+			"cluster":  connection("cluster/aws/ecs:fargate", 103),
+			"postgres": connection("datastore/aws/postgres:rds", 104),
+		}),
+		cluster.Uid.String(): runConfig(cluster, "nullstone/aws-fargate", types.Connections{
+			"network": connection("network/aws/vpc", 105),
+		}),
+		postgres.Uid.String(): runConfig(postgres, "nullstone/aws-rds-postgres", types.Connections{
+			"network": connection("network/aws/vpc", 105),
+		}),
+		network.Uid.String(): runConfig(network, "nullstone/aws-network", types.Connections{
+			"cluster": connection("cluster/aws/ecs:fargate", 103),
+		}),
+	}
+	return []types.Workspace{app, cluster, postgres, network}, runConfigs
+}
+
+func TestBuildConnectionGraph(t *testing.T) {
+	allWorkspaces, runConfigs := mockConnectionGraph()
+	root := types.WorkspaceTarget{StackId: 100, BlockId: 101, EnvId: 102}
+	node := func(blockId int64, blockName, module, contract string, depth int) connectionGraphNode {
+		return connectionGraphNode{
+			Workspace: types.WorkspaceTarget{StackId: 100, BlockId: blockId, EnvId: 102},
+			StackName: "stack0",
+			BlockName: blockName,
+			EnvName:   "env0",
+			Module:    module,
+			Contract:  contract,
+			Depth:     depth,
+		}
+	}
+
+	tests := []struct {
+		name             string
+		maxDepth         int
+		localConnections workspaces.ManifestConnections
+		// deleted is the block id of a workspace that no longer exists
+		deleted    int64
+		want       *connectionGraph
+		wantCycles []string
+	}{
+		{
+			name:     "walks every connection once",
+			maxDepth: 10,
+			want: &connectionGraph{
+				Nodes: []connectionGraphNode{
+					node(101, "app", "nullstone/aws-fargate-service", "", 0),
+					node(103, "cluster", "nullstone/aws-fargate", "cluster/aws/ecs:fargate", 1),
+					node(104, "postgres", "nullstone/aws-rds-postgres", "datastore/aws/postgres:rds", 1),
+					node(105, "network", "nullstone/aws-network", "network/aws/vpc", 2),
+				},
+				Edges: []connectionGraphEdge{
+					{From: "100/101/102", To: "100/103/102", Name: "cluster"},
+					{From: "100/101/102", To: "100/104/102", Name: "postgres"},
+					{From: "100/103/102", To: "100/105/102", Name: "network"},
+					{From: "100/104/102", To: "100/105/102", Name: "network"},
+					{From: "100/105/102", To: "100/103/102", Name: "cluster"},
+				},
+			},
+			wantCycles: []string{"cluster -> network -> cluster"},
+		},
+		{
+			name:     "stops at max depth",
+			maxDepth: 1,
+			want: &connectionGraph{
+				Nodes: []connectionGraphNode{
+					node(101, "app", "nullstone/aws-fargate-service", "", 0),
+					node(103, "cluster", "nullstone/aws-fargate", "cluster/aws/ecs:fargate", 1),
+					node(104, "postgres", "nullstone/aws-rds-postgres", "datastore/aws/postgres:rds", 1),
+				},
+				Edges: []connectionGraphEdge{
+					{From: "100/101/102", To: "100/103/102", Name: "cluster"},
+					{From: "100/101/102", To: "100/104/102", Name: "postgres"},
+				},
+				Truncated: true,
+			},
+			wantCycles: []string{},
+		},
+		{
+			name:     "local connections override the current workspace",
+			maxDepth: 10,
+			localConnections: workspaces.ManifestConnections{
+				"cluster": {StackId: 100, BlockId: 105},
+			},
+			want: &connectionGraph{
+				Nodes: []connectionGraphNode{
+					node(101, "app", "nullstone/aws-fargate-service", "", 0),
+					node(105, "network", "nullstone/aws-network", "cluster/aws/ecs:fargate", 1),
+					node(104, "postgres", "nullstone/aws-rds-postgres", "datastore/aws/postgres:rds", 1),
+					node(103, "cluster", "nullstone/aws-fargate", "cluster/aws/ecs:fargate", 2),
+				},
+				Edges: []connectionGraphEdge{
+					{From: "100/101/102", To: "100/105/102", Name: "cluster"},
+					{From: "100/101/102", To: "100/104/102", Name: "postgres"},
+					{From: "100/105/102", To: "100/103/102", Name: "cluster"},
+					{From: "100/104/102", To: "100/105/102", Name: "network"},
+					{From: "100/103/102", To: "100/105/102", Name: "network"},
+				},
+			},
+			wantCycles: []string{"network -> cluster -> network"},
+		},
+		{
+			name:     "records workspaces that cannot be retrieved",
+			maxDepth: 10,
+			deleted:  103,
+			want: &connectionGraph{
+				Nodes: []connectionGraphNode{
+					node(101, "app", "nullstone/aws-fargate-service", "", 0),
+					{
+						Workspace: types.WorkspaceTarget{StackId: 100, BlockId: 103, EnvId: 102},
+						Contract:  "cluster/aws/ecs:fargate",
+						Depth:     1,
+						Error:     "no nullstone workspace 100/103/102",
+					},
+					node(104, "postgres", "nullstone/aws-rds-postgres", "datastore/aws/postgres:rds", 1),
+					node(105, "network", "nullstone/aws-network", "network/aws/vpc", 2),
+				},
+				Edges: []connectionGraphEdge{
+					{From: "100/101/102", To: "100/103/102", Name: "cluster"},
+					{From: "100/101/102", To: "100/104/102", Name: "postgres"},
+					{From: "100/104/102", To: "100/105/102", Name: "network"},
+					{From: "100/105/102", To: "100/103/102", Name: "cluster"},
+				},
+			},
+			wantCycles: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			existing := make([]types.Workspace, 0, len(allWorkspaces))
+			for _, workspace := range allWorkspaces {
+				if workspace.BlockId != test.deleted {
+					existing = append(existing, workspace)
+				}
+			}
+			getNsConfig, closeNsFn := mockNs(mockNsServerWith(existing, runConfigs))
+			defer closeNsFn()
+			nsConfig := getNsConfig()
+			nsConfig.OrgName = "org0"
+
+			got, err := buildConnectionGraph(context.Background(), &runConfigResolver{NsConfig: nsConfig}, root, "", test.localConnections, test.maxDepth)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+			assert.Equal(t, test.wantCycles, got.Cycles())
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/nullstone-io/terraform-provider-ns/internal/server"
+	"github.com/nullstone-io/terraform-provider-ns/ns"
+)
+
+var capabilityConnectionType = tftypes.Object{
+	AttributeTypes: map[string]tftypes.Type{
+		"contract":     tftypes.String,
+		"workspace_id": tftypes.String,
+	},
+}
+
+var _ server.DataSource = &dataCapability{}
+
+type dataCapability struct {
+	p *provider
+}
+
+func newDataCapability(p *provider) (*dataCapability, error) {
+	if p == nil {
+		return nil, fmt.Errorf("a provider is required")
+	}
+	return &dataCapability{p: p}, nil
+}
+
+func (*dataCapability) Schema(ctx context.Context) *tfprotov5.Schema {
+	return &tfprotov5.Schema{
+		Version: 1,
+		Block: &tfprotov5.SchemaBlock{
+			Description: `Data source to read the capability that the provider is scoped to with ` + "`capability_name`" + `.
+The capability is read from the latest run config of the application.`,
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Attributes: []*tfprotov5.SchemaAttribute{
+				deprecatedIDAttribute(),
+				{
+					Name:            "name",
+					Type:            tftypes.String,
+					Computed:        true,
+					Description:     "The name of the capability.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:            "module",
+					Type:            tftypes.String,
+					Computed:        true,
+					Description:     "The module used for this capability (e.g. `nullstone/aws-s3-access`).",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:            "module_version",
+					Type:            tftypes.String,
+					Computed:        true,
+					Description:     "The effective version of the capability module.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:            "namespace",
+					Type:            tftypes.String,
+					Computed:        true,
+					Description:     "The namespace of the capability. This is used to prefix environment variables and secrets emitted by the capability.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:     "variables",
+					Type:     tftypes.DynamicPseudoType,
+					Computed: true,
+					Description: `An object containing the value of each variable of the capability keyed by variable name.
+Sensitive variables are excluded.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name: "connections",
+					Type: tftypes.Map{ElementType: capabilityConnectionType},
+					Description: `A map of the capability's connections keyed by connection name.
+Each connection contains ` + "`contract` and `workspace_id`" + `. ` + "`workspace_id`" + ` is empty if the connection is not connected to another workspace.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Computed:        true,
+				},
+			},
+		},
+	}
+}
+
+func (d *dataCapability) Validate(ctx context.Context, config map[string]tftypes.Value) ([]*tfprotov5.Diagnostic, error) {
+	return nil, nil
+}
+
+func (d *dataCapability) Read(ctx context.Context, config map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov5.Diagnostic, error) {
+	diags := make([]*tfprotov5.Diagnostic, 0)
+	capabilityName := d.p.PlanConfig.CapabilityName
+	if capabilityName == "" {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  "The provider is not scoped to a capability.",
+			Detail:   "Set capability_name in the provider to read a capability of the application.",
+		})
+		return nil, diags, nil
+	}
+
+	sourceWorkspace := d.p.PlanConfig.WorkspaceTarget()
+	log.Printf("(dataCapability.Read) Pulling workspace run config for @ %s", sourceWorkspace.Id())
+	runConfig, err := d.p.RunConfigs().GetRunConfig(ctx, sourceWorkspace)
+	if err != nil {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  "Unable to retrieve the run config of the application.",
+			Detail:   err.Error(),
+		})
+		return nil, diags, nil
+	}
+	capability, err := findCapability(runConfig, capabilityName)
+	var capabilityNotFound *ErrCapabilityNotFound
+	if errors.As(err, &capabilityNotFound) {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  fmt.Sprintf("The capability %q does not exist.", capabilityName),
+			Detail:   err.Error(),
+		})
+		return nil, diags, nil
+	}
+
+	variables, err := ns.VariablesToProtov5(capability.Variables)
+	if err != nil {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  fmt.Sprintf("Unable to read the variables of capability %q.", capabilityName),
+			Detail:   err.Error(),
+		})
+		return nil, diags, nil
+	}
+
+	targets := resolveConnectionTargets(sourceWorkspace, capability.Connections, d.p.PlanConfig.Connections)
+	connections := map[string]tftypes.Value{}
+	for name, conn := range capability.Connections {
+		workspaceId := ""
+		if target, ok := targets[name]; ok {
+			workspaceId = target.Workspace.Id()
+		}
+		connections[name] = tftypes.NewValue(capabilityConnectionType, map[string]tftypes.Value{
+			"contract":     tftypes.NewValue(tftypes.String, conn.Contract),
+			"workspace_id": tftypes.NewValue(tftypes.String, workspaceId),
+		})
+	}
+
+	return map[string]tftypes.Value{
+		"id":             tftypes.NewValue(tftypes.String, fmt.Sprintf("%s/%s", sourceWorkspace.Id(), capabilityName)),
+		"name":           tftypes.NewValue(tftypes.String, capability.Name),
+		"module":         tftypes.NewValue(tftypes.String, capability.Source),
+		"module_version": tftypes.NewValue(tftypes.String, capability.SourceVersion),
+		"namespace":      tftypes.NewValue(tftypes.String, capability.Namespace),
+		"variables":      variables,
+		"connections":    tftypes.NewValue(tftypes.Map{ElementType: capabilityConnectionType}, connections),
+	}, diags, nil
+}
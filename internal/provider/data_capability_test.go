@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/nullstone-io/module/config"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+func mockCapabilityWorkspace() (types.Workspace, map[string]types.RunConfig) {
+	uid := uuid.New()
+	workspace := types.Workspace{
+		UidCreatedModel: types.UidCreatedModel{Uid: uid},
+		OrgName:         "org0",
+		StackId:         100,
+		StackName:       "stack0",
+		BlockId:         101,
+		BlockName:       "api",
+		EnvId:           102,
+		EnvName:         "env0",
+	}
+	runConfigs := map[string]types.RunConfig{
+		uid.String(): {
+			WorkspaceUid: uid,
+			WorkspaceConfig: types.WorkspaceConfig{
+				Capabilities: []types.CapabilityConfig{
+					{
+						Name:          "bucket",
+						Source:        "nullstone/aws-s3-access",
+						SourceVersion: "0.5.1",
+						Namespace:     "uploads",
+						Variables: types.Variables{
+							"read_only":  {Value: true},
+							"access_key": {Variable: config.Variable{Sensitive: true}, Value: "secret"},
+						},
+						Connections: types.Connections{
+							"bucket": {
+								Connection:      config.Connection{Contract: "datastore/aws/s3"},
+								EffectiveTarget: &types.ConnectionTarget{StackId: 100, BlockId: 103},
+							},
+							"kms": {Connection: config.Connection{Contract: "datastore/aws/kms"}},
+						},
+					},
+					{Name: "secrets", Source: "nullstone/aws-secrets"},
+				},
+			},
+		},
+	}
+	return workspace, runConfigs
+}
+
+func TestDataCapability(t *testing.T) {
+	t.Setenv("NULLSTONE_STACK_ID", "100")
+	t.Setenv("NULLSTONE_BLOCK_ID", "101")
+	t.Setenv("NULLSTONE_ENV_ID", "102")
+	workspace, runConfigs := mockCapabilityWorkspace()
+
+	t.Run("reads the capability from the run config", func(t *testing.T) {
+		tfconfig := `
+provider "ns" {
+  organization    = "org0"
+  capability_name = "bucket"
+}
+data "ns_capability" "this" {}
+`
+		checks := resource.ComposeTestCheckFunc(
+			resource.TestCheckResourceAttr("data.ns_capability.this", `name`, "bucket"),
+			resource.TestCheckResourceAttr("data.ns_capability.this", `module`, "nullstone/aws-s3-access"),
+			resource.TestCheckResourceAttr("data.ns_capability.this", `module_version`, "0.5.1"),
+			resource.TestCheckResourceAttr("data.ns_capability.this", `namespace`, "uploads"),
+			resource.TestCheckResourceAttr("data.ns_capability.this", `variables.read_only`, "true"),
+			resource.TestCheckNoResourceAttr("data.ns_capability.this", `variables.access_key`),
+			resource.TestCheckResourceAttr("data.ns_capability.this", `connections.bucket.contract`, "datastore/aws/s3"),
+			resource.TestCheckResourceAttr("data.ns_capability.this", `connections.bucket.workspace_id`, "100/103/102"),
+			resource.TestCheckResourceAttr("data.ns_capability.this", `connections.kms.workspace_id`, ""),
+		)
+
+		getNsConfig, closeNsFn := mockNs(mockNsServerWith([]types.Workspace{workspace}, runConfigs))
+		defer closeNsFn()
+		getTfeConfig, _ := mockTfe(nil)
+
+		resource.UnitTest(t, resource.TestCase{
+			ProtoV5ProviderFactories: protoV5ProviderFactories(getNsConfig, getTfeConfig, nil),
+			Steps: []resource.TestStep{
+				{
+					Config: tfconfig,
+					Check:  checks,
+				},
+			},
+		})
+	})
+
+	t.Run("fails when capability_name does not match a capability", func(t *testing.T) {
+		tfconfig := `
+provider "ns" {
+  organization    = "org0"
+  capability_name = "buckets"
+}
+data "ns_capability" "this" {}
+`
+		getNsConfig, closeNsFn := mockNs(mockNsServerWith([]types.Workspace{workspace}, runConfigs))
+		defer closeNsFn()
+		getTfeConfig, _ := mockTfe(nil)
+
+		resource.UnitTest(t, resource.TestCase{
+			ProtoV5ProviderFactories: protoV5ProviderFactories(getNsConfig, getTfeConfig, nil),
+			Steps: []resource.TestStep{
+				{
+					Config:      tfconfig,
+					ExpectError: regexp.MustCompile(`available capabilities: bucket, secrets`),
+				},
+			},
+		})
+	})
+}
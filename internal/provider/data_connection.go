@@ -7,12 +7,15 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
 	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/nullstone-io/terraform-provider-ns/internal/server"
 	"github.com/nullstone-io/terraform-provider-ns/ns"
-	"gopkg.in/nullstone-io/go-api-client.v0"
 	"gopkg.in/nullstone-io/go-api-client.v0/types"
 )
 
@@ -76,6 +79,33 @@ func (*dataConnection) Schema(ctx context.Context) *tfprotov5.Schema {
 Typically, this is set to data.ns_connection.other.name`,
 					DescriptionKind: tfprotov5.StringKindMarkdown,
 				},
+				{
+					Name:     "required_outputs",
+					Type:     tftypes.Map{ElementType: tftypes.String},
+					Optional: true,
+					Description: `A map of output names to type constraints (e.g. ` + "`string`, `list(string)`, `any`" + `) that the connected workspace must provide.
+This data source will cause an error listing every missing or mistyped output.
+If the outputs cannot be read, each required output that ` + "`default_outputs`" + ` does not supply is reported as unavailable.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:     "default_outputs",
+					Type:     tftypes.DynamicPseudoType,
+					Optional: true,
+					Description: `The value of ` + "`outputs`" + ` when this connection is missing or its outputs cannot be read.
+Typically, this is used with ` + "`optional = true`" + ` to avoid checking whether each output exists.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				strictAttribute(),
+				maxOutputAgeAttribute(),
+				requireSuccessfulRunAttribute(),
+				{
+					Name:            "connected",
+					Type:            tftypes.Bool,
+					Computed:        true,
+					Description:     "True if this connection is satisfied by another workspace.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
 				{
 					Name:            "workspace_id",
 					Type:            tftypes.String,
@@ -90,26 +120,71 @@ Typically, this is set to data.ns_connection.other.name`,
 					Description:     `An object containing every root-level output in the remote state.`,
 					DescriptionKind: tfprotov5.StringKindMarkdown,
 				},
+				{
+					Name:            "stack_name",
+					Type:            tftypes.String,
+					Computed:        true,
+					Description:     "The name of the stack that owns the connected workspace.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:            "block_name",
+					Type:            tftypes.String,
+					Computed:        true,
+					Description:     "The name of the block of the connected workspace.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:            "env_name",
+					Type:            tftypes.String,
+					Computed:        true,
+					Description:     "The name of the environment of the connected workspace.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:            "module",
+					Type:            tftypes.String,
+					Computed:        true,
+					Description:     "The module used by the connected workspace (e.g. `nullstone/aws-network`).",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:            "module_version",
+					Type:            tftypes.String,
+					Computed:        true,
+					Description:     "The effective version of the module used by the connected workspace.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:            "state_updated_at",
+					Type:            tftypes.String,
+					Computed:        true,
+					Description:     "The time (RFC 3339) that the state of the connected workspace was last updated. This is empty if the state could not be read.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
 			},
 		},
 	}
 }
 
 func (d *dataConnection) Validate(ctx context.Context, config map[string]tftypes.Value) ([]*tfprotov5.Diagnostic, error) {
-	return nil, nil
+	_, diags := parseRequiredOutputs(config)
+	_, stalenessDiags := parseStalenessPolicy(config)
+	return append(diags, stalenessDiags...), nil
 }
 
 func (d *dataConnection) Read(ctx context.Context, config map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov5.Diagnostic, error) {
-	nsClient := api.Client{Config: d.p.NsConfig}
-
 	name := extractStringFromConfig(config, "name")
 	type_ := extractStringFromConfig(config, "type")
 	contract := extractStringFromConfig(config, "contract")
 	optional := extractBoolFromConfig(config, "optional")
 	via := extractStringFromConfig(config, "via")
+	requiredOutputs, diags := parseRequiredOutputs(config)
+	staleness, stalenessDiags := parseStalenessPolicy(config)
+	diags = append(diags, stalenessDiags...)
+	strict := d.p.strictFromConfig(config)
 	workspaceId := ""
 
-	diags := make([]*tfprotov5.Diagnostic, 0)
 	if !validConnectionName.Match([]byte(name)) {
 		diags = append(diags, &tfprotov5.Diagnostic{
 			Severity: tfprotov5.DiagnosticSeverityError,
@@ -134,9 +209,22 @@ func (d *dataConnection) Read(ctx context.Context, config map[string]tftypes.Val
 	}
 
 	outputsValue := tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, map[string]tftypes.Value{})
+	var defaultOutputs *tftypes.Value
+	if val, ok := config["default_outputs"]; ok && !val.IsNull() {
+		defaultOutputs = &val
+		outputsValue = val
+	}
 
-	workspace, err := d.getConnectionWorkspace(ctx, name, contractName, type_, via)
-	if err != nil {
+	var metadata connectionMetadata
+	workspace, missing, err := d.getConnectionWorkspace(ctx, name, contractName, type_, via)
+	var capabilityNotFound *ErrCapabilityNotFound
+	if errors.As(err, &capabilityNotFound) {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  fmt.Sprintf("The capability %q does not exist.", capabilityNotFound.Name),
+			Detail:   err.Error(),
+		})
+	} else if err != nil {
 		diags = append(diags, &tfprotov5.Diagnostic{
 			Severity: tfprotov5.DiagnosticSeverityError,
 			Summary:  "Unable to find nullstone workspace.",
@@ -144,53 +232,102 @@ func (d *dataConnection) Read(ctx context.Context, config map[string]tftypes.Val
 		})
 	} else if workspace != nil {
 		workspaceId = workspace.Id()
-		nfWorkspace, err := nsClient.Workspaces().Get(ctx, workspace.StackId, workspace.BlockId, workspace.EnvId)
-		if err != nil {
-			diags = append(diags, &tfprotov5.Diagnostic{
-				Severity: tfprotov5.DiagnosticSeverityError,
-				Summary:  fmt.Sprintf(`Unable to find nullstone workspace %s`, workspace.Id()),
-				Detail:   err.Error(),
-			})
-		} else {
-			stateFile, err := ns.GetStateFile(d.p.TfeClient, d.p.PlanConfig.OrgName, nfWorkspace.Uid.String())
-			if err != nil {
+		outputs, outputDiags := d.p.readWorkspaceOutputs(ctx, *workspace, readOutputsOptions{Default: defaultOutputs, Strict: strict})
+		diags = append(diags, outputDiags...)
+		diags = append(diags, staleness.Check(workspaceId, outputs, time.Now(), strict)...)
+		outputsValue = outputs.Value
+		metadata = newConnectionMetadata(outputs)
+		if outputs.StateFile != nil {
+			if problems := outputs.StateFile.Outputs.CheckRequired(requiredOutputs); len(problems) > 0 {
 				diags = append(diags, &tfprotov5.Diagnostic{
-					Severity: tfprotov5.DiagnosticSeverityWarning,
-					Summary:  fmt.Sprintf(`Unable to download workspace outputs for %q. 'outputs' will be empty`, workspace.Id()),
-					Detail:   err.Error(),
+					Severity: tfprotov5.DiagnosticSeverityError,
+					Summary:  fmt.Sprintf("The connection %q (%s) does not provide the required outputs.", name, workspaceId),
+					Detail:   strings.Join(problems, "\n"),
 				})
-			} else {
-				if ov, err := stateFile.Outputs.ToProtov5(); err != nil {
-					diags = append(diags, &tfprotov5.Diagnostic{
-						Severity: tfprotov5.DiagnosticSeverityWarning,
-						Summary:  fmt.Sprintf(`Unable to read workspace outputs for %q. 'outputs' will be empty`, workspace),
-						Detail:   err.Error(),
-					})
-				} else {
-					outputsValue = ov
-				}
 			}
+		} else if problems := unavailableRequiredOutputs(requiredOutputs, defaultOutputs); len(problems) > 0 {
+			diags = append(diags, &tfprotov5.Diagnostic{
+				Severity: tfprotov5.DiagnosticSeverityError,
+				Summary:  fmt.Sprintf("The required outputs of connection %q (%s) are unavailable.", name, workspaceId),
+				Detail:   "The outputs of the connected workspace could not be read and default_outputs does not supply them.\n" + strings.Join(problems, "\n"),
+			})
 		}
 	} else if !optional {
-		diags = append(diags, &tfprotov5.Diagnostic{
+		diag := &tfprotov5.Diagnostic{
 			Severity: tfprotov5.DiagnosticSeverityError,
 			Summary:  fmt.Sprintf("The connection %q is missing. It is required to use this plan.", name),
-		})
+		}
+		if missing != nil {
+			diag.Detail = missing.Detail()
+		}
+		diags = append(diags, diag)
 	}
 
 	return map[string]tftypes.Value{
-		"id":           tftypes.NewValue(tftypes.String, fmt.Sprintf("%s-%s", name, workspaceId)),
-		"name":         tftypes.NewValue(tftypes.String, name),
-		"type":         tftypes.NewValue(tftypes.String, type_),
-		"contract":     tftypes.NewValue(tftypes.String, contract),
-		"workspace_id": tftypes.NewValue(tftypes.String, workspaceId),
-		"optional":     tftypes.NewValue(tftypes.Bool, optional),
-		"via":          tftypes.NewValue(tftypes.String, via),
-		"outputs":      outputsValue,
+		"id":                     tftypes.NewValue(tftypes.String, fmt.Sprintf("%s-%s", name, workspaceId)),
+		"name":                   tftypes.NewValue(tftypes.String, name),
+		"type":                   tftypes.NewValue(tftypes.String, type_),
+		"contract":               tftypes.NewValue(tftypes.String, contract),
+		"workspace_id":           tftypes.NewValue(tftypes.String, workspaceId),
+		"optional":               tftypes.NewValue(tftypes.Bool, optional),
+		"via":                    tftypes.NewValue(tftypes.String, via),
+		"connected":              tftypes.NewValue(tftypes.Bool, workspace != nil),
+		"default_outputs":        config["default_outputs"],
+		"strict":                 config["strict"],
+		"max_output_age":         config["max_output_age"],
+		"require_successful_run": config["require_successful_run"],
+		"outputs":                outputsValue,
+		"required_outputs":       config["required_outputs"],
+		"stack_name":             tftypes.NewValue(tftypes.String, metadata.StackName),
+		"block_name":             tftypes.NewValue(tftypes.String, metadata.BlockName),
+		"env_name":               tftypes.NewValue(tftypes.String, metadata.EnvName),
+		"module":                 tftypes.NewValue(tftypes.String, metadata.Module),
+		"module_version":         tftypes.NewValue(tftypes.String, metadata.ModuleVersion),
+		"state_updated_at":       tftypes.NewValue(tftypes.String, metadata.StateUpdatedAt),
 	}, diags, nil
 }
 
-func (d *dataConnection) getConnectionWorkspace(ctx context.Context, name string, contractName types.ModuleContractName, type_, via string) (*types.WorkspaceTarget, error) {
+// unavailableRequiredOutputs reports each required output that is not supplied by defaultOutputs
+// This is used when the outputs of the connected workspace could not be read
+func unavailableRequiredOutputs(required map[string]cty.Type, defaultOutputs *tftypes.Value) []string {
+	supplied := map[string]tftypes.Value{}
+	if defaultOutputs != nil {
+		if err := defaultOutputs.As(&supplied); err != nil {
+			supplied = map[string]tftypes.Value{}
+		}
+	}
+	problems := make([]string, 0)
+	for name := range required {
+		if _, ok := supplied[name]; !ok {
+			problems = append(problems, fmt.Sprintf("output %q is unavailable", name))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// parseRequiredOutputs parses the type constraint of each output in `required_outputs`
+func parseRequiredOutputs(config map[string]tftypes.Value) (map[string]cty.Type, []*tfprotov5.Diagnostic) {
+	diags := make([]*tfprotov5.Diagnostic, 0)
+	required := map[string]cty.Type{}
+	for name, constraint := range TfValueToMap(config["required_outputs"]) {
+		t, err := ns.ParseTypeConstraint(constraint)
+		if err != nil {
+			diags = append(diags, &tfprotov5.Diagnostic{
+				Severity: tfprotov5.DiagnosticSeverityError,
+				Summary:  fmt.Sprintf("required_outputs[%q] is invalid", name),
+				Detail:   err.Error(),
+			})
+			continue
+		}
+		required[name] = t
+	}
+	return required, diags
+}
+
+// getConnectionWorkspace finds the workspace that satisfies the connection
+// If the connection is not found, this returns a nil workspace and a description of where the connection was searched
+func (d *dataConnection) getConnectionWorkspace(ctx context.Context, name string, contractName types.ModuleContractName, type_, via string) (*types.WorkspaceTarget, *missingConnection, error) {
 	log.Printf("(getConnectionWorkspace) name=%s contract=%s type=%s via=%s capabilityName=%s", name, contractName, type_, via, d.p.PlanConfig.CapabilityName)
 	sourceWorkspace := d.p.PlanConfig.WorkspaceTarget()
 
@@ -206,66 +343,86 @@ func (d *dataConnection) getConnectionWorkspace(ctx context.Context, name string
 			}
 			found := sourceWorkspace.FindRelativeConnection(ct)
 			log.Printf("(getConnectionWorkspace) Found workspace defined in plan config @ %s", found.Id())
-			return &found, nil
+			return &found, nil, nil
 		}
 	}
 
 	log.Printf("(getConnectionWorkspace) Pulling workspace run config for @ %s", sourceWorkspace.Id())
-	runConfig, err := ns.GetWorkspaceConfig(ctx, d.p.NsConfig, sourceWorkspace)
+	runConfig, err := d.p.RunConfigs().GetRunConfig(ctx, sourceWorkspace)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// If this data_connection is established on the capability, we need to pull from the correct set of connections
-	connections := d.getConnectionsFromRunConfig(runConfig)
+	connections, err := d.getConnectionsFromRunConfig(runConfig)
+	if err != nil {
+		return nil, nil, err
+	}
 	raw, _ := json.Marshal(connections)
 	log.Printf("(getConnectionWorkspace) Utilizing connections (capability name=%s) %s", d.p.PlanConfig.CapabilityName, string(raw))
 
 	// If this data_connection has `via` specified, then we need to
 	//   get the connections for *that* workspace instead of the current workspace
 	if via != "" {
-		sourceWorkspace, connections, err = walkViaConnection(ctx, d.p.NsConfig, sourceWorkspace, connections, localConnections, via)
+		sourceName := d.p.PlanConfig.BlockName
+		if sourceName == "" {
+			sourceName = sourceWorkspace.Id()
+		}
+		sourceWorkspace, connections, err = walkViaConnection(ctx, d.p.RunConfigs(), sourceWorkspace, sourceName, connections, localConnections, via, d.p.MaxViaHops)
 		if errors.Is(err, &ErrViaConnectionNotFound{}) {
 			log.Printf("(getConnectionWorkspace) %s\n", err)
-			return nil, nil
+			missing := newMissingConnection(name, sourceWorkspace, nil, localConnections)
+			missing.Via, missing.Reason = via, err.Error()
+			return nil, missing, nil
 		} else if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
 	conn, ok := connections[name]
 	if !ok || conn.EffectiveTarget == nil {
 		log.Printf("(getConnectionWorkspace) Connection (%s) was not found in %s", name, sourceWorkspace.Id())
-		return nil, nil
+		missing := newMissingConnection(name, sourceWorkspace, connections, localConnections)
+		missing.Via = via
+		if via == "" && !d.isAppConnection {
+			missing.addCapabilities(runConfig, d.p.PlanConfig.CapabilityName)
+		}
+		return nil, missing, nil
 	}
 	if err := d.validateConnection(conn, contractName, type_); err != nil {
-		return nil, fmt.Errorf("workspace (%s) is configured with invalid connection: %w", sourceWorkspace.Id(), err)
+		return nil, nil, fmt.Errorf("workspace (%s) is configured with invalid connection: %w", sourceWorkspace.Id(), err)
 	}
 	found := sourceWorkspace.FindRelativeConnection(*conn.EffectiveTarget)
 	log.Printf("(getConnectionWorkspace) Found workspace in connections @ %s", found.Id())
-	return &found, nil
+	return &found, nil, nil
 }
 
-func (d *dataConnection) getConnectionsFromRunConfig(runConfig *types.RunConfig) types.Connections {
-	if runConfig == nil {
-		return types.Connections{}
-	}
-
+func (d *dataConnection) getConnectionsFromRunConfig(runConfig *types.RunConfig) (types.Connections, error) {
 	// If this is an app connection, we immediately return those
 	if d.isAppConnection {
-		return runConfig.Connections
+		return connectionsFromRunConfig(runConfig, "")
 	}
+	return connectionsFromRunConfig(runConfig, d.p.PlanConfig.CapabilityName)
+}
+
+// connectionsFromRunConfig retrieves the connections from the run config
+// If capabilityName is not empty, this returns the connections of that capability instead
+// If the application has no capability named capabilityName, this returns ErrCapabilityNotFound
+func connectionsFromRunConfig(runConfig *types.RunConfig, capabilityName string) (types.Connections, error) {
+	if runConfig == nil {
+		return types.Connections{}, nil
+	}
+
 	// If the provider is configured with a non-empty capability name
 	//   we should use the connections from that capability
-	if capabilityName := d.p.PlanConfig.CapabilityName; capabilityName != "" {
-		for _, cur := range runConfig.Capabilities {
-			if cur.Name == capabilityName {
-				return cur.Connections
-			}
+	if capabilityName != "" {
+		capability, err := findCapability(runConfig, capabilityName)
+		if err != nil {
+			return nil, err
 		}
-		return types.Connections{}
+		return capability.Connections, nil
 	}
-	return runConfig.Connections
+	return runConfig.Connections, nil
 }
 
 func (d *dataConnection) validateConnection(conn types.Connection, wantContractName types.ModuleContractName, wantType string) error {
@@ -0,0 +1,169 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/nullstone-io/terraform-provider-ns/internal/server"
+)
+
+const defaultConnectionGraphMaxDepth = 10
+
+var (
+	connectionGraphNodeType = tftypes.Object{
+		AttributeTypes: map[string]tftypes.Type{
+			"workspace_id": tftypes.String,
+			"stack_name":   tftypes.String,
+			"block_name":   tftypes.String,
+			"env_name":     tftypes.String,
+			"module":       tftypes.String,
+			"contract":     tftypes.String,
+			"depth":        tftypes.Number,
+			"error":        tftypes.String,
+		},
+	}
+	connectionGraphEdgeType = tftypes.Object{
+		AttributeTypes: map[string]tftypes.Type{
+			"from": tftypes.String,
+			"to":   tftypes.String,
+			"name": tftypes.String,
+		},
+	}
+)
+
+var _ server.DataSource = &dataConnectionGraph{}
+
+type dataConnectionGraph struct {
+	p *provider
+}
+
+func newDataConnectionGraph(p *provider) (*dataConnectionGraph, error) {
+	if p == nil {
+		return nil, fmt.Errorf("a provider is required")
+	}
+	return &dataConnectionGraph{p: p}, nil
+}
+
+func (*dataConnectionGraph) Schema(ctx context.Context) *tfprotov5.Schema {
+	return &tfprotov5.Schema{
+		Version: 1,
+		Block: &tfprotov5.SchemaBlock{
+			Description:     "Data source to read every workspace reachable through connections from the current nullstone workspace.",
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Attributes: []*tfprotov5.SchemaAttribute{
+				deprecatedIDAttribute(),
+				{
+					Name:            "max_depth",
+					Type:            tftypes.Number,
+					Optional:        true,
+					Description:     fmt.Sprintf("The maximum number of connections to follow from the current workspace. (Default: `%d`)", defaultConnectionGraphMaxDepth),
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:     "nodes",
+					Type:     tftypes.List{ElementType: connectionGraphNodeType},
+					Computed: true,
+					Description: `Every workspace in the graph, starting with the current workspace.
+Each node contains ` + "`workspace_id`, `stack_name`, `block_name`, `env_name`, `module`, `contract`, `depth`, and `error`" + `.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:     "edges",
+					Type:     tftypes.List{ElementType: connectionGraphEdgeType},
+					Computed: true,
+					Description: `Every connection in the graph.
+Each edge contains ` + "`from` and `to` (workspace ids) and `name` (the connection name)" + `.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:            "truncated",
+					Type:            tftypes.Bool,
+					Computed:        true,
+					Description:     "True if some connections were not followed because of `max_depth`.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+			},
+		},
+	}
+}
+
+func (d *dataConnectionGraph) Validate(ctx context.Context, config map[string]tftypes.Value) ([]*tfprotov5.Diagnostic, error) {
+	if config["max_depth"].IsNull() || !config["max_depth"].IsKnown() {
+		return nil, nil
+	}
+	if maxDepth := extractInt64FromConfig(config, "max_depth"); maxDepth < 1 {
+		return []*tfprotov5.Diagnostic{
+			{
+				Severity: tfprotov5.DiagnosticSeverityError,
+				Summary:  fmt.Sprintf("max_depth (%d) must be at least 1", maxDepth),
+			},
+		}, nil
+	}
+	return nil, nil
+}
+
+func (d *dataConnectionGraph) Read(ctx context.Context, config map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov5.Diagnostic, error) {
+	maxDepth := int64(defaultConnectionGraphMaxDepth)
+	if !config["max_depth"].IsNull() {
+		maxDepth = extractInt64FromConfig(config, "max_depth")
+	}
+
+	diags := make([]*tfprotov5.Diagnostic, 0)
+	root := d.p.PlanConfig.WorkspaceTarget()
+	graph, err := buildConnectionGraph(ctx, d.p.RunConfigs(), root, d.p.PlanConfig.CapabilityName, d.p.PlanConfig.Connections, int(maxDepth))
+	if err != nil {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  "Unable to build connection graph.",
+			Detail:   err.Error(),
+		})
+		return nil, diags, nil
+	}
+	for _, cycle := range graph.Cycles() {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: tfprotov5.DiagnosticSeverityWarning,
+			Summary:  "Connection graph contains a cycle",
+			Detail:   cycle,
+		})
+	}
+
+	nodes := make([]tftypes.Value, 0, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		if node.Error != "" {
+			diags = append(diags, &tfprotov5.Diagnostic{
+				Severity: tfprotov5.DiagnosticSeverityWarning,
+				Summary:  fmt.Sprintf("Unable to retrieve workspace %q. Its connections are not included in the connection graph.", node.Workspace.Id()),
+				Detail:   node.Error,
+			})
+		}
+		depth := int64(node.Depth)
+		nodes = append(nodes, tftypes.NewValue(connectionGraphNodeType, map[string]tftypes.Value{
+			"workspace_id": tftypes.NewValue(tftypes.String, node.Workspace.Id()),
+			"stack_name":   tftypes.NewValue(tftypes.String, node.StackName),
+			"block_name":   tftypes.NewValue(tftypes.String, node.BlockName),
+			"env_name":     tftypes.NewValue(tftypes.String, node.EnvName),
+			"module":       tftypes.NewValue(tftypes.String, node.Module),
+			"contract":     tftypes.NewValue(tftypes.String, node.Contract),
+			"depth":        tftypes.NewValue(tftypes.Number, &depth),
+			"error":        tftypes.NewValue(tftypes.String, node.Error),
+		}))
+	}
+	edges := make([]tftypes.Value, 0, len(graph.Edges))
+	for _, edge := range graph.Edges {
+		edges = append(edges, tftypes.NewValue(connectionGraphEdgeType, map[string]tftypes.Value{
+			"from": tftypes.NewValue(tftypes.String, edge.From),
+			"to":   tftypes.NewValue(tftypes.String, edge.To),
+			"name": tftypes.NewValue(tftypes.String, edge.Name),
+		}))
+	}
+
+	return map[string]tftypes.Value{
+		"id":        tftypes.NewValue(tftypes.String, root.Id()),
+		"max_depth": config["max_depth"],
+		"nodes":     tftypes.NewValue(tftypes.List{ElementType: connectionGraphNodeType}, nodes),
+		"edges":     tftypes.NewValue(tftypes.List{ElementType: connectionGraphEdgeType}, edges),
+		"truncated": tftypes.NewValue(tftypes.Bool, graph.Truncated),
+	}, diags, nil
+}
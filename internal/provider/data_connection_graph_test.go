@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestDataConnectionGraph(t *testing.T) {
+	t.Setenv("NULLSTONE_STACK_ID", "100")
+	t.Setenv("NULLSTONE_BLOCK_ID", "101")
+	t.Setenv("NULLSTONE_ENV_ID", "102")
+	allWorkspaces, runConfigs := mockConnectionGraph()
+
+	t.Run("walks connections up to max depth", func(t *testing.T) {
+		tfconfig := `
+provider "ns" {
+  organization = "org0"
+}
+data "ns_connection_graph" "this" {
+  max_depth = 1
+}
+`
+		checks := resource.ComposeTestCheckFunc(
+			resource.TestCheckResourceAttr("data.ns_connection_graph.this", `nodes.#`, "3"),
+			resource.TestCheckResourceAttr("data.ns_connection_graph.this", `nodes.0.block_name`, "app"),
+			resource.TestCheckResourceAttr("data.ns_connection_graph.this", `nodes.1.block_name`, "cluster"),
+			resource.TestCheckResourceAttr("data.ns_connection_graph.this", `nodes.2.block_name`, "postgres"),
+			resource.TestCheckResourceAttr("data.ns_connection_graph.this", `edges.#`, "2"),
+			resource.TestCheckResourceAttr("data.ns_connection_graph.this", `edges.0.name`, "cluster"),
+			resource.TestCheckResourceAttr("data.ns_connection_graph.this", `edges.0.to`, "100/103/102"),
+			resource.TestCheckResourceAttr("data.ns_connection_graph.this", `truncated`, "true"),
+		)
+
+		getNsConfig, closeNsFn := mockNs(mockNsServerWith(allWorkspaces, runConfigs))
+		defer closeNsFn()
+		getTfeConfig, _ := mockTfe(nil)
+
+		resource.UnitTest(t, resource.TestCase{
+			ProtoV5ProviderFactories: protoV5ProviderFactories(getNsConfig, getTfeConfig, nil),
+			Steps: []resource.TestStep{
+				{
+					Config: tfconfig,
+					Check:  checks,
+				},
+			},
+		})
+	})
+}
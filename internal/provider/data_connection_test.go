@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/nullstone-io/module/config"
+	"github.com/stretchr/testify/assert"
 	"gopkg.in/nullstone-io/go-api-client.v0/types"
 	"gopkg.in/nullstone-io/nullstone.v0/workspaces"
 	"net/http"
@@ -211,6 +214,43 @@ data "ns_connection" "postgres" {
 
 	})
 
+	t.Run("uses default outputs when optional and connection is not configured", func(t *testing.T) {
+		tfconfig := fmt.Sprintf(`
+provider "ns" {
+  organization = "org0"
+}
+data "ns_connection" "postgres" {
+  name     = "postgres"
+  contract = "datastore/aws/postgres:rds"
+  optional = true
+  default_outputs = {
+    db_endpoint        = ""
+    security_group_ids = []
+  }
+}
+`)
+		checks := resource.ComposeTestCheckFunc(
+			resource.TestCheckResourceAttr("data.ns_connection.postgres", `connected`, "false"),
+			resource.TestCheckResourceAttr("data.ns_connection.postgres", `workspace_id`, ""),
+			resource.TestCheckResourceAttr("data.ns_connection.postgres", `outputs.db_endpoint`, ""),
+			resource.TestCheckResourceAttr("data.ns_connection.postgres", `outputs.security_group_ids.#`, "0"),
+		)
+
+		getNsConfig, closeNsFn := mockNs(mockNsServerWith(allWorkspaces, runConfigs))
+		defer closeNsFn()
+		getTfeConfig, _ := mockTfe(nil)
+
+		resource.UnitTest(t, resource.TestCase{
+			ProtoV5ProviderFactories: protoV5ProviderFactories(getNsConfig, getTfeConfig, nil),
+			Steps: []resource.TestStep{
+				{
+					Config: tfconfig,
+					Check:  checks,
+				},
+			},
+		})
+	})
+
 	t.Run("sets up attributes properly", func(t *testing.T) {
 		tfconfig := fmt.Sprintf(`
 provider "ns" {
@@ -222,6 +262,7 @@ data "ns_connection" "cluster" {
 }
 `)
 		checks := resource.ComposeTestCheckFunc(
+			resource.TestCheckResourceAttr("data.ns_connection.cluster", `connected`, "true"),
 			resource.TestCheckResourceAttr("data.ns_connection.cluster", `workspace_id`, "100/103/102"),
 			resource.TestCheckResourceAttr("data.ns_connection.cluster", `outputs.test1`, "value1"),
 			resource.TestCheckResourceAttr("data.ns_connection.cluster", `outputs.test2`, "2"),
@@ -246,6 +287,74 @@ data "ns_connection" "cluster" {
 		})
 	})
 
+	t.Run("fails when required outputs are missing or mistyped", func(t *testing.T) {
+		tfconfig := fmt.Sprintf(`
+provider "ns" {
+  organization = "org0"
+}
+data "ns_connection" "cluster" {
+  name     = "cluster"
+  contract = "cluster/aws/ecs"
+  required_outputs = {
+    test1       = "string"
+    test2       = "list(string)"
+    test3       = "map(string)"
+    cluster_arn = "string"
+  }
+}
+`)
+
+		getNsConfig, closeNsFn := mockNs(mockNsServerWith(allWorkspaces, runConfigs))
+		defer closeNsFn()
+		getTfeConfig, closeTfeFn := mockTfe(mockStateServerWith(enigmaEnv0, lycanEnv0, rikiEnv0))
+		defer closeTfeFn()
+
+		resource.UnitTest(t, resource.TestCase{
+			ProtoV5ProviderFactories: protoV5ProviderFactories(getNsConfig, getTfeConfig, nil),
+			Steps: []resource.TestStep{
+				{
+					Config:      tfconfig,
+					ExpectError: regexp.MustCompile(`output "cluster_arn" is missing\s+output "test2" has type number, expected list of string`),
+				},
+			},
+		})
+	})
+
+	t.Run("fails when required outputs are unavailable", func(t *testing.T) {
+		tfconfig := fmt.Sprintf(`
+provider "ns" {
+  organization = "org0"
+}
+data "ns_connection" "cluster" {
+  name     = "cluster"
+  contract = "cluster/aws/ecs"
+  required_outputs = {
+    test1       = "string"
+    cluster_arn = "string"
+  }
+  default_outputs = {
+    test1 = "default"
+  }
+}
+`)
+
+		getNsConfig, closeNsFn := mockNs(mockNsServerWith(allWorkspaces, runConfigs))
+		defer closeNsFn()
+		// The state backend has no workspaces, so the connection has not been applied yet
+		getTfeConfig, closeTfeFn := mockTfe(mux.NewRouter())
+		defer closeTfeFn()
+
+		resource.UnitTest(t, resource.TestCase{
+			ProtoV5ProviderFactories: protoV5ProviderFactories(getNsConfig, getTfeConfig, nil),
+			Steps: []resource.TestStep{
+				{
+					Config:      tfconfig,
+					ExpectError: regexp.MustCompile(`output "cluster_arn" is unavailable`),
+				},
+			},
+		})
+	})
+
 	t.Run("sets up attributes with via properly", func(t *testing.T) {
 		tfconfig := fmt.Sprintf(`
 provider "ns" {
@@ -641,3 +750,14 @@ func mockTfeStatePull(workspaces map[string]json.RawMessage, currentStateVersion
 		})
 	return router
 }
+
+func TestUnavailableRequiredOutputs(t *testing.T) {
+	required := map[string]cty.Type{"cluster_arn": cty.String, "vpc_id": cty.String}
+	defaults := tftypes.NewValue(tftypes.Object{AttributeTypes: map[string]tftypes.Type{"vpc_id": tftypes.String}}, map[string]tftypes.Value{
+		"vpc_id": tftypes.NewValue(tftypes.String, ""),
+	})
+
+	assert.Equal(t, []string{`output "cluster_arn" is unavailable`, `output "vpc_id" is unavailable`}, unavailableRequiredOutputs(required, nil))
+	assert.Equal(t, []string{`output "cluster_arn" is unavailable`}, unavailableRequiredOutputs(required, &defaults))
+	assert.Empty(t, unavailableRequiredOutputs(nil, nil))
+}
@@ -0,0 +1,205 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/nullstone-io/terraform-provider-ns/internal/server"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+	"gopkg.in/nullstone-io/nullstone.v0/workspaces"
+)
+
+var _ server.DataSource = &dataConnections{}
+
+type dataConnections struct {
+	p *provider
+}
+
+func newDataConnections(p *provider) (*dataConnections, error) {
+	if p == nil {
+		return nil, fmt.Errorf("a provider is required")
+	}
+	return &dataConnections{p: p}, nil
+}
+
+func (*dataConnections) Schema(ctx context.Context) *tfprotov5.Schema {
+	return &tfprotov5.Schema{
+		Version: 1,
+		Block: &tfprotov5.SchemaBlock{
+			Description:     "Data source to read every connection of the current nullstone workspace.",
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Attributes: []*tfprotov5.SchemaAttribute{
+				deprecatedIDAttribute(),
+				{
+					Name:     "contract",
+					Type:     tftypes.String,
+					Optional: true,
+					Description: `Only include connections whose contract matches this contract.
+Wildcards are supported (e.g. ` + "`datastore/aws/*`" + `).`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				strictAttribute(),
+				{
+					Name:            "names",
+					Type:            tftypes.List{ElementType: tftypes.String},
+					Computed:        true,
+					Description:     "The sorted names of the matching connections.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name: "connections",
+					Type: tftypes.DynamicPseudoType,
+					Description: `An object containing each matching connection keyed by connection name.
+Each connection contains ` + "`name`, `contract`, `workspace_id`, and `outputs`" + `.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+					Computed:        true,
+				},
+			},
+		},
+	}
+}
+
+func (d *dataConnections) Validate(ctx context.Context, config map[string]tftypes.Value) ([]*tfprotov5.Diagnostic, error) {
+	return nil, nil
+}
+
+func (d *dataConnections) Read(ctx context.Context, config map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov5.Diagnostic, error) {
+	contract := extractStringFromConfig(config, "contract")
+	strict := d.p.strictFromConfig(config)
+
+	diags := make([]*tfprotov5.Diagnostic, 0)
+	var contractFilter *types.ModuleContractName
+	if contract != "" {
+		contractName, err := types.ParseModuleContractName(contract)
+		if err != nil {
+			diags = append(diags, &tfprotov5.Diagnostic{
+				Severity: tfprotov5.DiagnosticSeverityError,
+				Summary:  fmt.Sprintf("contract (%s) is invalid: %s", contract, err),
+			})
+			return nil, diags, nil
+		}
+		contractFilter = &contractName
+	}
+
+	targets, err := d.getConnectionTargets(ctx, contractFilter)
+	if err != nil {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  "Unable to retrieve connections.",
+			Detail:   err.Error(),
+		})
+		return nil, diags, nil
+	}
+
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nameValues := make([]tftypes.Value, 0, len(names))
+	connTypes := map[string]tftypes.Type{}
+	connValues := map[string]tftypes.Value{}
+	for _, name := range names {
+		target := targets[name]
+		workspaceId := target.Workspace.Id()
+		outputs, outputDiags := d.p.readWorkspaceOutputs(ctx, target.Workspace, readOutputsOptions{Strict: strict})
+		diags = append(diags, outputDiags...)
+		outputsValue := outputs.Value
+
+		connType := tftypes.Object{
+			AttributeTypes: map[string]tftypes.Type{
+				"name":         tftypes.String,
+				"contract":     tftypes.String,
+				"workspace_id": tftypes.String,
+				"outputs":      outputsValue.Type(),
+			},
+		}
+		nameValues = append(nameValues, tftypes.NewValue(tftypes.String, name))
+		connTypes[name] = connType
+		connValues[name] = tftypes.NewValue(connType, map[string]tftypes.Value{
+			"name":         tftypes.NewValue(tftypes.String, name),
+			"contract":     tftypes.NewValue(tftypes.String, target.Contract),
+			"workspace_id": tftypes.NewValue(tftypes.String, workspaceId),
+			"outputs":      outputsValue,
+		})
+	}
+
+	return map[string]tftypes.Value{
+		"id":          tftypes.NewValue(tftypes.String, d.p.PlanConfig.WorkspaceTarget().Id()),
+		"contract":    tftypes.NewValue(tftypes.String, contract),
+		"strict":      config["strict"],
+		"names":       tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, nameValues),
+		"connections": tftypes.NewValue(tftypes.Object{AttributeTypes: connTypes}, connValues),
+	}, diags, nil
+}
+
+type connectionTarget struct {
+	Contract  string
+	Workspace types.WorkspaceTarget
+}
+
+// getConnectionTargets retrieves the workspace for each connection of the current workspace that matches contractFilter
+// See resolveConnectionTargets for how each connection is resolved
+func (d *dataConnections) getConnectionTargets(ctx context.Context, contractFilter *types.ModuleContractName) (map[string]connectionTarget, error) {
+	sourceWorkspace := d.p.PlanConfig.WorkspaceTarget()
+	log.Printf("(getConnectionTargets) Pulling workspace run config for @ %s", sourceWorkspace.Id())
+	runConfig, err := d.p.RunConfigs().GetRunConfig(ctx, sourceWorkspace)
+	if err != nil {
+		return nil, err
+	}
+
+	connections, err := connectionsFromRunConfig(runConfig, d.p.PlanConfig.CapabilityName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]connectionTarget{}
+	targets := resolveConnectionTargets(sourceWorkspace, connections, d.p.PlanConfig.Connections)
+	for name, target := range targets {
+		if matchesContractFilter(target.Contract, contractFilter) {
+			result[name] = target
+		}
+	}
+	return result, nil
+}
+
+// resolveConnectionTargets finds the workspace for each connection relative to sourceWorkspace
+// A local connection from the plan config overrides the effective target of a connection
+// Connections that are not configured in Nullstone (no effective target) are excluded
+func resolveConnectionTargets(sourceWorkspace types.WorkspaceTarget, connections types.Connections, localConnections workspaces.ManifestConnections) map[string]connectionTarget {
+	result := map[string]connectionTarget{}
+	for name, conn := range connections {
+		target := connectionTarget{Contract: conn.Contract}
+		if reference, ok := localConnections[name]; ok {
+			target.Workspace = sourceWorkspace.FindRelativeConnection(types.ConnectionTarget{
+				StackId:   reference.StackId,
+				BlockId:   reference.BlockId,
+				BlockName: reference.BlockName,
+				EnvId:     reference.EnvId,
+			})
+		} else if conn.EffectiveTarget != nil {
+			target.Workspace = sourceWorkspace.FindRelativeConnection(*conn.EffectiveTarget)
+		} else {
+			log.Printf("(resolveConnectionTargets) Connection (%s) is not configured in %s", name, sourceWorkspace.Id())
+			continue
+		}
+		result[name] = target
+	}
+	return result
+}
+
+func matchesContractFilter(contract string, contractFilter *types.ModuleContractName) bool {
+	if contractFilter == nil {
+		return true
+	}
+	contractName, err := types.ParseModuleContractName(contract)
+	if err != nil {
+		return false
+	}
+	return contractFilter.Match(contractName)
+}
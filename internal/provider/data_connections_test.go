@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-tfe"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/nullstone-io/module/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+	"gopkg.in/nullstone-io/nullstone.v0/workspaces"
+)
+
+func mockConnectionsWorkspace() (types.Workspace, map[string]types.RunConfig) {
+	uid := uuid.New()
+	workspace := types.Workspace{
+		UidCreatedModel: types.UidCreatedModel{Uid: uid},
+		OrgName:         "org0",
+		StackId:         100,
+		StackName:       "stack0",
+		BlockId:         101,
+		BlockName:       "sidecar",
+		EnvId:           102,
+		EnvName:         "env0",
+	}
+	connection := func(contract string, blockId int64) types.Connection {
+		conn := types.Connection{Connection: config.Connection{Contract: contract}}
+		if blockId != 0 {
+			conn.EffectiveTarget = &types.ConnectionTarget{StackId: 100, BlockId: blockId}
+		}
+		return conn
+	}
+	runConfigs := map[string]types.RunConfig{
+		uid.String(): {
+			WorkspaceUid: uid,
+			WorkspaceConfig: types.WorkspaceConfig{
+				Connections: map[string]types.Connection{
+					"postgres": connection("datastore/aws/postgres:rds", 103),
+					"redis":    connection("datastore/aws/redis:elasticache", 104),
+					"cluster":  connection("cluster/aws/ecs:fargate", 105),
+					"mysql":    connection("datastore/aws/mysql:rds", 0),
+				},
+			},
+		},
+	}
+	return workspace, runConfigs
+}
+
+func TestDataConnections_getConnectionTargets(t *testing.T) {
+	workspace, runConfigs := mockConnectionsWorkspace()
+
+	tests := []struct {
+		name             string
+		contract         string
+		localConnections workspaces.ManifestConnections
+		want             map[string]connectionTarget
+	}{
+		{
+			name: "all configured connections",
+			want: map[string]connectionTarget{
+				"postgres": {Contract: "datastore/aws/postgres:rds", Workspace: types.WorkspaceTarget{StackId: 100, BlockId: 103, EnvId: 102}},
+				"redis":    {Contract: "datastore/aws/redis:elasticache", Workspace: types.WorkspaceTarget{StackId: 100, BlockId: 104, EnvId: 102}},
+				"cluster":  {Contract: "cluster/aws/ecs:fargate", Workspace: types.WorkspaceTarget{StackId: 100, BlockId: 105, EnvId: 102}},
+			},
+		},
+		{
+			name:     "filtered by contract",
+			contract: "datastore/aws/*",
+			want: map[string]connectionTarget{
+				"postgres": {Contract: "datastore/aws/postgres:rds", Workspace: types.WorkspaceTarget{StackId: 100, BlockId: 103, EnvId: 102}},
+				"redis":    {Contract: "datastore/aws/redis:elasticache", Workspace: types.WorkspaceTarget{StackId: 100, BlockId: 104, EnvId: 102}},
+			},
+		},
+		{
+			name:     "local connections override the target",
+			contract: "datastore/*/*",
+			localConnections: workspaces.ManifestConnections{
+				"postgres": {StackId: 100, BlockId: 200},
+				"mysql":    {StackId: 100, BlockId: 201},
+			},
+			want: map[string]connectionTarget{
+				"postgres": {Contract: "datastore/aws/postgres:rds", Workspace: types.WorkspaceTarget{StackId: 100, BlockId: 200, EnvId: 102}},
+				"redis":    {Contract: "datastore/aws/redis:elasticache", Workspace: types.WorkspaceTarget{StackId: 100, BlockId: 104, EnvId: 102}},
+				"mysql":    {Contract: "datastore/aws/mysql:rds", Workspace: types.WorkspaceTarget{StackId: 100, BlockId: 201, EnvId: 102}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			getNsConfig, closeNsFn := mockNs(mockNsServerWith([]types.Workspace{workspace}, runConfigs))
+			defer closeNsFn()
+			nsConfig := getNsConfig()
+			nsConfig.OrgName = "org0"
+
+			planConfig := PlanConfig{OrgName: "org0", StackId: 100, BlockId: 101, EnvId: 102, Connections: test.localConnections}
+			d := &dataConnections{p: &provider{NsConfig: nsConfig, TfeConfig: tfe.DefaultConfig(), PlanConfig: &planConfig}}
+
+			var contractFilter *types.ModuleContractName
+			if test.contract != "" {
+				contractName, err := types.ParseModuleContractName(test.contract)
+				require.NoError(t, err)
+				contractFilter = &contractName
+			}
+			got, err := d.getConnectionTargets(context.Background(), contractFilter)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestDataConnections(t *testing.T) {
+	t.Setenv("NULLSTONE_STACK_ID", "100")
+	t.Setenv("NULLSTONE_BLOCK_ID", "101")
+	t.Setenv("NULLSTONE_ENV_ID", "102")
+	workspace, runConfigs := mockConnectionsWorkspace()
+
+	t.Run("lists connections matching contract", func(t *testing.T) {
+		tfconfig := `
+provider "ns" {
+  organization = "org0"
+  state_dir    = "test-fixtures/empty-state"
+}
+data "ns_connections" "datastores" {
+  contract = "datastore/aws/*"
+}
+`
+		checks := resource.ComposeTestCheckFunc(
+			resource.TestCheckResourceAttr("data.ns_connections.datastores", `names.#`, "2"),
+			resource.TestCheckResourceAttr("data.ns_connections.datastores", `names.0`, "postgres"),
+			resource.TestCheckResourceAttr("data.ns_connections.datastores", `names.1`, "redis"),
+		)
+
+		getNsConfig, closeNsFn := mockNs(mockNsServerWith([]types.Workspace{workspace}, runConfigs))
+		defer closeNsFn()
+		getTfeConfig, _ := mockTfe(nil)
+
+		resource.UnitTest(t, resource.TestCase{
+			ProtoV5ProviderFactories: protoV5ProviderFactories(getNsConfig, getTfeConfig, nil),
+			Steps: []resource.TestStep{
+				{
+					Config: tfconfig,
+					Check:  checks,
+				},
+			},
+		})
+	})
+}
@@ -2,7 +2,11 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
 	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
@@ -71,14 +75,29 @@ func (*dataEnvVariables) Schema(ctx context.Context) *tfprotov5.Schema {
 	}
 }
 
+// interpolationCycleDiagnostics reports each cycle of env vars that reference each other
+func interpolationCycleDiagnostics(cycles [][]string) []*tfprotov5.Diagnostic {
+	diags := make([]*tfprotov5.Diagnostic, 0)
+	for _, cycle := range cycles {
+		keys := slices.Clone(cycle[:len(cycle)-1])
+		slices.Sort(keys)
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  fmt.Sprintf("Environment variables reference each other in a cycle: %s", strings.Join(cycle, " -> ")),
+			Detail:   fmt.Sprintf("The env variables (%s) can never be fully interpolated. Remove one of the references or escape it with {{{{ NAME }}}}.", strings.Join(keys, ", ")),
+		})
+	}
+	return diags
+}
+
 func (d *dataEnvVariables) Validate(ctx context.Context, config map[string]tftypes.Value) ([]*tfprotov5.Diagnostic, error) {
 	inputEnvVariables := TfValueToMap(config["input_env_variables"])
 	inputSecrets := TfValueToMap(config["input_secrets"])
 
-	errors := make([]*tfprotov5.Diagnostic, 0)
+	diags := make([]*tfprotov5.Diagnostic, 0)
 	for key, _ := range inputEnvVariables {
 		if !validEnvVariableKey(key) {
-			errors = append(errors, &tfprotov5.Diagnostic{
+			diags = append(diags, &tfprotov5.Diagnostic{
 				Severity: tfprotov5.DiagnosticSeverityError,
 				Summary:  fmt.Sprintf("Invalid environment variable key: %s", key),
 				Detail:   "An environment variable key can only contain letters, numbers, and the underscore character. It also can not begin with a number.",
@@ -87,15 +106,16 @@ func (d *dataEnvVariables) Validate(ctx context.Context, config map[string]tftyp
 	}
 	for key, _ := range inputSecrets {
 		if !validEnvVariableKey(key) {
-			errors = append(errors, &tfprotov5.Diagnostic{
+			diags = append(diags, &tfprotov5.Diagnostic{
 				Severity: tfprotov5.DiagnosticSeverityError,
 				Summary:  fmt.Sprintf("Invalid environment variable key: %s", key),
 				Detail:   "An environment variable key can only contain letters, numbers, and the underscore character. It also can not begin with a number.",
 			})
 		}
 	}
+	diags = append(diags, interpolationCycleDiagnostics(NewEnvVars(inputEnvVariables, inputSecrets).Cycles())...)
 
-	return errors, nil
+	return diags, nil
 }
 
 func (d *dataEnvVariables) Read(ctx context.Context, config map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov5.Diagnostic, error) {
@@ -106,7 +126,10 @@ func (d *dataEnvVariables) Read(ctx context.Context, config map[string]tftypes.V
 	tflog.Debug(ctx, "input_secrets", inputSecrets)
 
 	ev := NewEnvVars(TfValueToMap(inputEnvVariables), TfValueToMap(inputSecrets))
-	ev.Interpolate()
+	var cycleErr *ErrInterpolationCycle
+	if err := ev.Interpolate(); errors.As(err, &cycleErr) {
+		return nil, interpolationCycleDiagnostics(cycleErr.Cycles), nil
+	}
 
 	// calculate the unique id for this data source based on a hash of the resulting env variables and secrets
 	id := ev.Hash()
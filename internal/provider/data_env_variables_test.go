@@ -1,10 +1,14 @@
 package provider
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDataVariables(t *testing.T) {
@@ -57,3 +61,20 @@ data "ns_env_variables" "this" {
 		})
 	})
 }
+
+func TestDataEnvVariables_Validate(t *testing.T) {
+	d := &dataEnvVariables{}
+	config := map[string]tftypes.Value{
+		"input_env_variables": MapToTfValue(map[string]string{
+			"A":     "x{{ B }}",
+			"B":     "y{{ A }}",
+			"OTHER": "{{ A }}",
+		}),
+		"input_secrets": MapToTfValue(map[string]string{}),
+	}
+	diags, err := d.Validate(context.Background(), config)
+	require.NoError(t, err)
+	if assert.Len(t, diags, 1) {
+		assert.Equal(t, "Environment variables reference each other in a cycle: A -> B -> A", diags[0].Summary)
+	}
+}
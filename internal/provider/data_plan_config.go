@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+type dataPlanConfig struct {
+	p *provider
+}
+
+func newDataPlanConfig(p *provider) (*dataPlanConfig, error) {
+	if p == nil {
+		return nil, fmt.Errorf("a provider is required")
+	}
+	return &dataPlanConfig{p: p}, nil
+}
+
+func (*dataPlanConfig) Schema(ctx context.Context) *tfprotov5.Schema {
+	attrs := []*tfprotov5.SchemaAttribute{
+		deprecatedIDAttribute(),
+		{
+			Name:            "org_name",
+			Type:            tftypes.String,
+			Description:     "The name of the organization the provider is configured for.",
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Computed:        true,
+		},
+		{
+			Name:            "stack_id",
+			Type:            tftypes.Number,
+			Description:     "The ID of the stack the provider is configured for.",
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Computed:        true,
+		},
+		{
+			Name:            "stack_name",
+			Type:            tftypes.String,
+			Description:     "The name of the stack the provider is configured for.",
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Computed:        true,
+		},
+		{
+			Name:            "block_id",
+			Type:            tftypes.Number,
+			Description:     "The ID of the block the provider is configured for.",
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Computed:        true,
+		},
+		{
+			Name:            "block_name",
+			Type:            tftypes.String,
+			Description:     "The name of the block the provider is configured for.",
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Computed:        true,
+		},
+		{
+			Name:            "block_ref",
+			Type:            tftypes.String,
+			Description:     "The reference of the block the provider is configured for.",
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Computed:        true,
+		},
+		{
+			Name:            "env_id",
+			Type:            tftypes.Number,
+			Description:     "The ID of the environment the provider is configured for.",
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Computed:        true,
+		},
+		{
+			Name:            "env_name",
+			Type:            tftypes.String,
+			Description:     "The name of the environment the provider is configured for.",
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Computed:        true,
+		},
+		{
+			Name:            "capability_name",
+			Type:            tftypes.String,
+			Description:     "The name of the capability the provider is configured for.",
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Computed:        true,
+		},
+		{
+			Name: "connections",
+			Type: tftypes.Map{ElementType: tftypes.String},
+			Description: `A map of connections configured locally in the plan config.
+Each value is the workspace target (` + "`<stack-id>/<block-id>/<env-id>`" + `) that the connection resolves to.`,
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Computed:        true,
+		},
+		{
+			Name: "sources",
+			Type: tftypes.Map{ElementType: tftypes.String},
+			Description: `A map of where each value came from, keyed by attribute name.
+Local connections are keyed by ` + "`connections.<name>`" + `.
+Possible values: ` + "`env:<ENV_VAR>`" + `, ` + "`.nullstone.json`" + `, ` + "`.nullstone/active-workspace.yml`" + `, ` + "`provider`" + `, ` + "`api`" + ` (resolved from the matching name).`,
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Computed:        true,
+		},
+	}
+
+	return &tfprotov5.Schema{
+		Version: 1,
+		Block: &tfprotov5.SchemaBlock{
+			Description:     "Data source to inspect which nullstone workspace the provider is configured for and where each value came from.",
+			DescriptionKind: tfprotov5.StringKindMarkdown,
+			Attributes:      attrs,
+		},
+	}
+}
+
+func (d *dataPlanConfig) Validate(ctx context.Context, config map[string]tftypes.Value) ([]*tfprotov5.Diagnostic, error) {
+	return nil, nil
+}
+
+func (d *dataPlanConfig) Read(ctx context.Context, config map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov5.Diagnostic, error) {
+	planConfig := d.p.PlanConfig
+	sourceWorkspace := planConfig.WorkspaceTarget()
+
+	connections := map[string]tftypes.Value{}
+	for name, reference := range planConfig.Connections {
+		target := sourceWorkspace.FindRelativeConnection(types.ConnectionTarget{
+			StackId:   reference.StackId,
+			BlockId:   reference.BlockId,
+			BlockName: reference.BlockName,
+			EnvId:     reference.EnvId,
+		})
+		connections[name] = tftypes.NewValue(tftypes.String, target.Id())
+	}
+	sources := map[string]tftypes.Value{}
+	for key, source := range d.p.PlanConfigSources {
+		sources[key] = tftypes.NewValue(tftypes.String, source)
+	}
+
+	return map[string]tftypes.Value{
+		"id":              tftypes.NewValue(tftypes.String, sourceWorkspace.Id()),
+		"org_name":        tftypes.NewValue(tftypes.String, planConfig.OrgName),
+		"stack_id":        tftypes.NewValue(tftypes.Number, &planConfig.StackId),
+		"stack_name":      tftypes.NewValue(tftypes.String, planConfig.StackName),
+		"block_id":        tftypes.NewValue(tftypes.Number, &planConfig.BlockId),
+		"block_name":      tftypes.NewValue(tftypes.String, planConfig.BlockName),
+		"block_ref":       tftypes.NewValue(tftypes.String, planConfig.BlockRef),
+		"env_id":          tftypes.NewValue(tftypes.Number, &planConfig.EnvId),
+		"env_name":        tftypes.NewValue(tftypes.String, planConfig.EnvName),
+		"capability_name": tftypes.NewValue(tftypes.String, planConfig.CapabilityName),
+		"connections":     tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, connections),
+		"sources":         tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, sources),
+	}, nil, nil
+}
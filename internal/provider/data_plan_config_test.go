@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestDataPlanConfig(t *testing.T) {
+	t.Run("reports the source of each value", func(t *testing.T) {
+		config := `
+provider "ns" {
+  organization = "org0"
+}
+data "ns_plan_config" "this" {}
+`
+		getNsConfig, _ := mockNs(nil)
+		getTfeConfig, _ := mockTfe(nil)
+
+		t.Setenv("NULLSTONE_STACK_ID", "100")
+		t.Setenv("NULLSTONE_BLOCK_ID", "101")
+		t.Setenv("NULLSTONE_ENV_ID", "102")
+
+		checks := resource.ComposeTestCheckFunc(
+			resource.TestCheckResourceAttr("data.ns_plan_config.this", "org_name", "org0"),
+			resource.TestCheckResourceAttr("data.ns_plan_config.this", "stack_id", "100"),
+			resource.TestCheckResourceAttr("data.ns_plan_config.this", "block_id", "101"),
+			resource.TestCheckResourceAttr("data.ns_plan_config.this", "env_id", "102"),
+			resource.TestCheckResourceAttr("data.ns_plan_config.this", "sources.org_name", "provider"),
+			resource.TestCheckResourceAttr("data.ns_plan_config.this", "sources.stack_id", "env:NULLSTONE_STACK_ID"),
+			resource.TestCheckResourceAttr("data.ns_plan_config.this", "sources.block_id", "env:NULLSTONE_BLOCK_ID"),
+			resource.TestCheckResourceAttr("data.ns_plan_config.this", "sources.env_id", "env:NULLSTONE_ENV_ID"),
+		)
+
+		resource.UnitTest(t, resource.TestCase{
+			ProtoV5ProviderFactories: protoV5ProviderFactories(getNsConfig, getTfeConfig, nil),
+			Steps: []resource.TestStep{
+				{
+					Config: config,
+					Check:  checks,
+				},
+			},
+		})
+	})
+}
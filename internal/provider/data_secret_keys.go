@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
 	"github.com/hashicorp/terraform-plugin-go/tftypes"
@@ -60,10 +61,10 @@ func (d *dataSecretKeys) Validate(ctx context.Context, config map[string]tftypes
 	inputEnvVariables := TfValueToMap(config["input_env_variables"])
 	inputSecretKeys := TfSetValueToStringSlice(config["input_secret_keys"])
 
-	errors := make([]*tfprotov5.Diagnostic, 0)
+	diags := make([]*tfprotov5.Diagnostic, 0)
 	for key, _ := range inputEnvVariables {
 		if !validEnvVariableKey(key) {
-			errors = append(errors, &tfprotov5.Diagnostic{
+			diags = append(diags, &tfprotov5.Diagnostic{
 				Severity: tfprotov5.DiagnosticSeverityError,
 				Summary:  fmt.Sprintf("Invalid environment variable key: %s", key),
 				Detail:   "An environment variable key can only contain letters, numbers, and the underscore character. It also can not begin with a number.",
@@ -72,15 +73,20 @@ func (d *dataSecretKeys) Validate(ctx context.Context, config map[string]tftypes
 	}
 	for _, key := range inputSecretKeys {
 		if !validEnvVariableKey(key) {
-			errors = append(errors, &tfprotov5.Diagnostic{
+			diags = append(diags, &tfprotov5.Diagnostic{
 				Severity: tfprotov5.DiagnosticSeverityError,
 				Summary:  fmt.Sprintf("Invalid environment variable key: %s", key),
 				Detail:   "An environment variable key can only contain letters, numbers, and the underscore character. It also can not begin with a number.",
 			})
 		}
 	}
+	inputSecrets := map[string]string{}
+	for _, key := range inputSecretKeys {
+		inputSecrets[key] = ""
+	}
+	diags = append(diags, interpolationCycleDiagnostics(NewEnvVars(inputEnvVariables, inputSecrets).Cycles())...)
 
-	return errors, nil
+	return diags, nil
 }
 
 func (d *dataSecretKeys) Read(ctx context.Context, config map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov5.Diagnostic, error) {
@@ -97,7 +103,10 @@ func (d *dataSecretKeys) Read(ctx context.Context, config map[string]tftypes.Val
 	}
 
 	ev := NewEnvVars(TfValueToMap(inputEnvVariables), inputSecrets)
-	ev.Interpolate()
+	var cycleErr *ErrInterpolationCycle
+	if err := ev.Interpolate(); errors.As(err, &cycleErr) {
+		return nil, interpolationCycleDiagnostics(cycleErr.Cycles), nil
+	}
 
 	id := ev.KeysHash()
 	secretKeys := ev.SecretKeys()
@@ -5,14 +5,30 @@ import (
 	"fmt"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 )
 
 var (
-	secretRefRegex               = regexp.MustCompile("{{\\s*secret\\((.+)\\)\\s*}}")
-	interpolationRefRegexPattern = "{{\\s*%s\\s*}}"
+	secretRefRegex = regexp.MustCompile("{{\\s*secret\\((.+)\\)\\s*}}")
+	// escapedRefPlaceholderRegex matches the placeholder that hides an escaped reference while finding a secret ref
+	escapedRefPlaceholderRegex = regexp.MustCompile("\\x00([0-9]+)\\x00")
 )
 
+// ErrInterpolationCycle occurs when env vars reference each other in a cycle (e.g. `A = "{{ B }}"`, `B = "{{ A }}"`)
+// Each cycle starts and ends with the same key (e.g. [A B A])
+type ErrInterpolationCycle struct {
+	Cycles [][]string
+}
+
+func (e *ErrInterpolationCycle) Error() string {
+	cycles := make([]string, 0, len(e.Cycles))
+	for _, cycle := range e.Cycles {
+		cycles = append(cycles, strings.Join(cycle, " -> "))
+	}
+	return fmt.Sprintf("env variables reference each other in a cycle: %s", strings.Join(cycles, "; "))
+}
+
 type EnvVars map[string]EnvVar
 
 func NewEnvVars(envVars map[string]string, secrets map[string]string) EnvVars {
@@ -73,82 +89,347 @@ func (m EnvVars) SecretKeys() []string {
 	return result
 }
 
-func (m EnvVars) Interpolate() {
-	// 1. Mark env var values with secret ref
-	// Scan all env vars, checking for `{{ secret(...) }}`
-	// Extract the secret ref and attach to the value
-	for k, v := range m {
-		result := secretRefRegex.FindStringSubmatch(v.Value)
-		if len(result) > 1 {
-			secretRef := result[1]
-			v.SecretRef = &secretRef
-			m[k] = v
-		}
-	}
-
-	// 2. Interpolate secrets onto other env vars
-	// This has the potential to promote env vars to secrets
-	// Loop until convergence to handle chained references (e.g. A → B → SECRET)
-	for changed := true; changed; {
-		changed = false
-		for k1, v1 := range m.Secrets() {
-			replacer := regexp.MustCompile(fmt.Sprintf(interpolationRefRegexPattern, k1))
-			for k2, v2 := range m.EnvVars() {
-				result := replacer.ReplaceAllString(v2, v1)
-				// if a match was found and replaced, this env variable is now a secret
-				if result != v2 {
-					changed = true
-					entry := m[k2]
-					entry.IsSensitive = true
-					entry.Value = result
-					m[k2] = entry
+// Interpolate replaces each reference to another env var or secret (`{{ NAME }}`) with its value
+// A reference to a key that does not exist is left as-is
+// An env var that references a secret (directly or through other env vars) is promoted to a secret
+// An env var whose value contains `{{ secret(...) }}` is recorded as a secret ref instead
+//
+// A reference to itself is left as-is (e.g. `PATH = "{{ PATH }}:/bin"`)
+//
+// An escaped reference, `{{{{ NAME }}}}`, produces the literal text `{{ NAME }}`
+// Escaped references are never interpolated (including `{{{{ secret(...) }}}}`),
+// and the literal text is not interpolated again when the env var is referenced by another env var
+//
+// Each value is tokenized once and env vars are resolved after the env vars they reference,
+// so each value is built in a single pass and substituted values are never scanned for references again
+// If env vars reference each other in a cycle, this returns ErrInterpolationCycle without interpolating
+func (m EnvVars) Interpolate() error {
+	parsed := m.parse()
+	if cycles := findCycles(parsed.references()); len(cycles) > 0 {
+		return &ErrInterpolationCycle{Cycles: cycles}
+	}
+
+	resolved := make(map[string]bool, len(m))
+	var resolve func(k string)
+	resolve = func(k string) {
+		if resolved[k] {
+			return
+		}
+		resolved[k] = true
+		v, p := m[k], parsed[k]
+		var sb strings.Builder
+		for _, token := range p.Tokens {
+			if token.Kind == refToken && p.Interpolated && token.Name != k {
+				if target, ok := parsed[token.Name]; ok && target.Interpolated {
+					// There are no cycles, so the referenced env var is fully resolved before it is substituted
+					resolve(token.Name)
+					sb.WriteString(m[token.Name].Value)
+					// If a secret was substituted, this env variable is now a secret
+					v.IsSensitive = v.IsSensitive || m[token.Name].IsSensitive
+					continue
 				}
 			}
-			for k2, v2 := range m.Secrets() {
-				if k2 != k1 {
-					result := replacer.ReplaceAllString(v2, v1)
-					if result != v2 {
-						changed = true
-						entry := m[k2]
-						entry.IsSensitive = true
-						entry.Value = result
-						m[k2] = entry
-					}
-				}
+			sb.WriteString(token.Text)
+		}
+		v.Value, v.SecretRef = sb.String(), p.SecretRef
+		m[k] = v
+	}
+	for k := range m {
+		resolve(k)
+	}
+	return nil
+}
+
+// References retrieves the keys that each env var references
+// Escaped references, references to keys that do not exist, and env vars with a secret ref are excluded
+// like they are during Interpolate
+func (m EnvVars) References() map[string][]string {
+	return m.parse().references()
+}
+
+// Cycles finds every group of env vars that reference each other in a cycle
+// An env var that references itself is only a cycle if another env var references it;
+// otherwise, the reference to itself is left as-is during Interpolate
+// Each cycle is described by the shortest path from its first key (alphabetically) back to itself (e.g. [A B A])
+// Cycles are sorted by their first key
+func (m EnvVars) Cycles() [][]string {
+	return findCycles(m.References())
+}
+
+// parsedEnvVar is an env var split into tokens before interpolation
+type parsedEnvVar struct {
+	Tokens []envVarToken
+	// SecretRef is the secret ref in the value (e.g. `{{ secret(...) }}`), nil if there is none
+	SecretRef *string
+	// Interpolated is true if Interpolate replaces references in the env var and references to the env var
+	// An env var with a secret ref is left alone unless it is a secret
+	Interpolated bool
+}
+
+type parsedEnvVars map[string]parsedEnvVar
+
+func (m EnvVars) parse() parsedEnvVars {
+	parsed := make(parsedEnvVars, len(m))
+	for k, v := range m {
+		tokens := tokenizeEnvVar(v.Value)
+		secretRef := findSecretRef(v.Value, tokens)
+		parsed[k] = parsedEnvVar{
+			Tokens:       tokens,
+			SecretRef:    secretRef,
+			Interpolated: v.IsSensitive || secretRef == nil,
+		}
+	}
+	return parsed
+}
+
+func (p parsedEnvVars) references() map[string][]string {
+	refs := map[string][]string{}
+	for k, v := range p {
+		if !v.Interpolated {
+			continue
+		}
+		for _, token := range v.Tokens {
+			if token.Kind != refToken {
+				continue
+			}
+			if target, ok := p[token.Name]; ok && target.Interpolated && !slices.Contains(refs[k], token.Name) {
+				refs[k] = append(refs[k], token.Name)
 			}
 		}
+		slices.Sort(refs[k])
 	}
+	return refs
+}
 
-	// 3. Interpolate env vars onto other env vars/secrets
-	// This will not promote anybody to a secret
-	// Loop until convergence to handle chained references (e.g. A → B → C)
-	for changed := true; changed; {
-		changed = false
-		for k1, v1 := range m.EnvVars() {
-			regex := regexp.MustCompile(fmt.Sprintf(interpolationRefRegexPattern, k1))
-			for k2, v2 := range m.EnvVars() {
-				// we don't want to replace the env variable with itself (this will prevent an infinite loop)
-				if k2 != k1 {
-					result := regex.ReplaceAllString(v2, v1)
-					if result != v2 {
-						changed = true
-						entry := m[k2]
-						entry.Value = result
-						m[k2] = entry
-					}
+// findSecretRef finds `{{ secret(...) }}` in value, ignoring escaped references
+// Escaped references are hidden behind a placeholder while matching secretRefRegex
+// so that they are matched exactly like the surrounding text, but never mistaken for a secret ref
+func findSecretRef(value string, tokens []envVarToken) *string {
+	if !strings.Contains(value, "secret(") {
+		return nil
+	}
+	literals := make([]string, 0)
+	var sb strings.Builder
+	for _, token := range tokens {
+		if token.Kind == escapedToken {
+			fmt.Fprintf(&sb, "\x00%d\x00", len(literals))
+			literals = append(literals, token.Text)
+			continue
+		}
+		sb.WriteString(token.Text)
+	}
+	result := secretRefRegex.FindStringSubmatch(sb.String())
+	if len(result) < 2 {
+		return nil
+	}
+	secretRef := escapedRefPlaceholderRegex.ReplaceAllStringFunc(result[1], func(match string) string {
+		i, err := strconv.Atoi(escapedRefPlaceholderRegex.FindStringSubmatch(match)[1])
+		if err != nil || i >= len(literals) {
+			return match
+		}
+		return literals[i]
+	})
+	return &secretRef
+}
+
+// findCycles finds every cycle in refs
+// See EnvVars.Cycles
+func findCycles(refs map[string][]string) [][]string {
+	keys := make([]string, 0, len(refs))
+	for k := range refs {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	// Tarjan's algorithm finds the strongly connected components of the reference graph
+	index, lowlink, onStack := map[string]int{}, map[string]int{}, map[string]bool{}
+	stack := make([]string, 0)
+	components := make([][]string, 0)
+	var visit func(k string)
+	visit = func(k string) {
+		index[k], lowlink[k] = len(index), len(index)
+		stack = append(stack, k)
+		onStack[k] = true
+		for _, next := range refs[k] {
+			if _, ok := index[next]; !ok {
+				visit(next)
+				lowlink[k] = min(lowlink[k], lowlink[next])
+			} else if onStack[next] {
+				lowlink[k] = min(lowlink[k], index[next])
+			}
+		}
+		if lowlink[k] == index[k] {
+			component := make([]string, 0)
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == k {
+					break
 				}
 			}
-			for k2, v2 := range m.Secrets() {
-				result := regex.ReplaceAllString(v2, v1)
-				if result != v2 {
-					changed = true
-					entry := m[k2]
-					entry.Value = result
-					m[k2] = entry
+			components = append(components, component)
+		}
+	}
+	for _, k := range keys {
+		if _, ok := index[k]; !ok {
+			visit(k)
+		}
+	}
+
+	referenced := map[string]bool{}
+	for k, targets := range refs {
+		for _, target := range targets {
+			if target != k {
+				referenced[target] = true
+			}
+		}
+	}
+	cycles := make([][]string, 0)
+	for _, component := range components {
+		if k := component[0]; len(component) == 1 && (!slices.Contains(refs[k], k) || !referenced[k]) {
+			continue
+		}
+		slices.Sort(component)
+		cycles = append(cycles, shortestCycle(refs, component))
+	}
+	slices.SortFunc(cycles, func(a, b []string) int { return strings.Compare(a[0], b[0]) })
+	return cycles
+}
+
+// shortestCycle finds the shortest path from the first key of component back to itself through keys in component
+func shortestCycle(refs map[string][]string, component []string) []string {
+	start := component[0]
+	previous := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, next := range refs[cur] {
+			if !slices.Contains(component, next) {
+				continue
+			}
+			if next == start {
+				path := []string{start}
+				for k := cur; k != start; k = previous[k] {
+					path = append(path, k)
 				}
+				path = append(path, start)
+				slices.Reverse(path)
+				return path
+			}
+			if _, ok := previous[next]; !ok {
+				previous[next] = cur
+				queue = append(queue, next)
 			}
 		}
 	}
+	return append(component, start)
+}
+
+type envVarTokenKind int
+
+const (
+	// literalToken is text that is copied as-is
+	literalToken envVarTokenKind = iota
+	// refToken is a reference to another env var (e.g. `{{ FOO }}`)
+	refToken
+	// escapedToken is an escaped reference (e.g. `{{{{ FOO }}}}`)
+	escapedToken
+)
+
+type envVarToken struct {
+	Kind envVarTokenKind
+	// Text is the text that the token produces if it is not interpolated
+	// For an escaped reference, this is the literal text without the extra braces (e.g. `{{ FOO }}`)
+	Text string
+	// Name is the key referenced by a refToken
+	Name string
+}
+
+// tokenizeEnvVar splits value into literal text, references, and escaped references in a single pass
+// A reference is `{{ NAME }}` where NAME contains no whitespace or braces
+// An escaped reference is `{{{{ ... }}}}` on a single line and takes precedence over a reference at the same position
+func tokenizeEnvVar(value string) []envVarToken {
+	tokens := make([]envVarToken, 0)
+	literalStart := 0
+	appendToken := func(start, end int, token envVarToken) {
+		if literalStart < start {
+			tokens = append(tokens, envVarToken{Kind: literalToken, Text: value[literalStart:start]})
+		}
+		tokens = append(tokens, token)
+		literalStart = end
+	}
+
+	for i := 0; i < len(value); {
+		next := strings.Index(value[i:], "{{")
+		if next < 0 {
+			break
+		}
+		i += next
+		if inner, end, ok := scanEscapedRef(value, i); ok {
+			appendToken(i, end, envVarToken{Kind: escapedToken, Text: "{{" + inner + "}}"})
+			i = end
+		} else if name, end, ok := scanRef(value, i); ok {
+			appendToken(i, end, envVarToken{Kind: refToken, Text: value[i:end], Name: name})
+			i = end
+		} else {
+			i++
+		}
+	}
+	if literalStart < len(value) {
+		tokens = append(tokens, envVarToken{Kind: literalToken, Text: value[literalStart:]})
+	}
+	return tokens
+}
+
+// scanEscapedRef scans `{{{{ ... }}}}` at value[start:], stopping at the first `}}}}`
+// This returns the text between the braces and the end of the escaped reference
+func scanEscapedRef(value string, start int) (string, int, bool) {
+	if !strings.HasPrefix(value[start:], "{{{{") {
+		return "", 0, false
+	}
+	rest := value[start+4:]
+	end := strings.Index(rest, "}}}}")
+	if end < 0 || strings.Contains(rest[:end], "\n") {
+		return "", 0, false
+	}
+	return rest[:end], start + 4 + end + 4, true
+}
+
+// scanRef scans `{{ NAME }}` at value[start:]
+// This returns the name and the end of the reference
+func scanRef(value string, start int) (string, int, bool) {
+	if !strings.HasPrefix(value[start:], "{{") {
+		return "", 0, false
+	}
+	i := skipRefSpace(value, start+2)
+	nameStart := i
+	for i < len(value) && isRefNameChar(value[i]) {
+		i++
+	}
+	if i == nameStart {
+		return "", 0, false
+	}
+	name := value[nameStart:i]
+	i = skipRefSpace(value, i)
+	if !strings.HasPrefix(value[i:], "}}") {
+		return "", 0, false
+	}
+	return name, i + 2, true
+}
+
+// skipRefSpace skips the whitespace allowed around the name of a reference (the same as `\s` in a regex)
+func skipRefSpace(value string, i int) int {
+	for i < len(value) && strings.IndexByte(" \t\n\f\r", value[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+func isRefNameChar(c byte) bool {
+	return c != '{' && c != '}' && strings.IndexByte(" \t\n\f\r", c) < 0
 }
 
 func (m EnvVars) Hash() string {
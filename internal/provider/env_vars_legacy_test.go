@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// This is the regex-based interpolation engine that EnvVars.Interpolate replaced
+// It is kept as a reference implementation for differential tests and benchmarks
+
+var (
+	legacyInterpolationRefRegexPattern = "{{\\s*%s\\s*}}"
+	legacyEscapedRefRegex              = regexp.MustCompile("{{{{(.*?)}}}}")
+)
+
+// interpolateLegacy interpolates m by repeatedly substituting each key into every other value until nothing changes
+// Unlike Interpolate, this does not detect cycles; it never converges if env vars reference each other in a cycle
+// or if an env var references another env var that references itself
+func (m EnvVars) interpolateLegacy() {
+	// 0. Hide escaped references so that they are not interpolated
+	literals := m.legacyEscapeLiterals()
+	defer m.legacyRestoreLiterals(literals)
+
+	// 1. Mark env var values with secret ref
+	for k, v := range m {
+		result := secretRefRegex.FindStringSubmatch(v.Value)
+		if len(result) > 1 {
+			secretRef := result[1]
+			v.SecretRef = &secretRef
+			m[k] = v
+		}
+	}
+
+	// 2. Interpolate secrets onto other env vars
+	// This has the potential to promote env vars to secrets
+	for changed := true; changed; {
+		changed = false
+		for k1, v1 := range m.Secrets() {
+			replacer := regexp.MustCompile(fmt.Sprintf(legacyInterpolationRefRegexPattern, k1))
+			for k2, v2 := range m.EnvVars() {
+				result := replacer.ReplaceAllString(v2, v1)
+				if result != v2 {
+					changed = true
+					entry := m[k2]
+					entry.IsSensitive = true
+					entry.Value = result
+					m[k2] = entry
+				}
+			}
+			for k2, v2 := range m.Secrets() {
+				if k2 != k1 {
+					result := replacer.ReplaceAllString(v2, v1)
+					if result != v2 {
+						changed = true
+						entry := m[k2]
+						entry.IsSensitive = true
+						entry.Value = result
+						m[k2] = entry
+					}
+				}
+			}
+		}
+	}
+
+	// 3. Interpolate env vars onto other env vars/secrets
+	// This will not promote anybody to a secret
+	for changed := true; changed; {
+		changed = false
+		for k1, v1 := range m.EnvVars() {
+			regex := regexp.MustCompile(fmt.Sprintf(legacyInterpolationRefRegexPattern, k1))
+			for k2, v2 := range m.EnvVars() {
+				if k2 != k1 {
+					result := regex.ReplaceAllString(v2, v1)
+					if result != v2 {
+						changed = true
+						entry := m[k2]
+						entry.Value = result
+						m[k2] = entry
+					}
+				}
+			}
+			for k2, v2 := range m.Secrets() {
+				result := regex.ReplaceAllString(v2, v1)
+				if result != v2 {
+					changed = true
+					entry := m[k2]
+					entry.Value = result
+					m[k2] = entry
+				}
+			}
+		}
+	}
+}
+
+func (m EnvVars) legacyEscapeLiterals() []string {
+	literals := make([]string, 0)
+	for k, v := range m {
+		if !strings.Contains(v.Value, "{{{{") {
+			continue
+		}
+		v.Value = legacyEscapedRefRegex.ReplaceAllStringFunc(v.Value, func(match string) string {
+			inner := legacyEscapedRefRegex.FindStringSubmatch(match)[1]
+			literals = append(literals, "{{"+inner+"}}")
+			return fmt.Sprintf("\x00%d\x00", len(literals)-1)
+		})
+		m[k] = v
+	}
+	return literals
+}
+
+func (m EnvVars) legacyRestoreLiterals(literals []string) {
+	if len(literals) == 0 {
+		return
+	}
+	restore := func(s string) string {
+		return escapedRefPlaceholderRegex.ReplaceAllStringFunc(s, func(match string) string {
+			i, err := strconv.Atoi(escapedRefPlaceholderRegex.FindStringSubmatch(match)[1])
+			if err != nil || i >= len(literals) {
+				return match
+			}
+			return literals[i]
+		})
+	}
+	for k, v := range m {
+		v.Value = restore(v.Value)
+		if v.SecretRef != nil {
+			secretRef := restore(*v.SecretRef)
+			v.SecretRef = &secretRef
+		}
+		m[k] = v
+	}
+}
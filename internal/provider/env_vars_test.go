@@ -2,9 +2,15 @@ package provider
 
 import (
 	"fmt"
+	"maps"
+	"math/rand"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMixedEnvVars_Interpolate(t *testing.T) {
@@ -75,12 +81,45 @@ func TestMixedEnvVars_Interpolate(t *testing.T) {
 			wantSecretRefs: map[string]string{},
 			wantSecretKeys: []string{"A", "B", "DATABASE_URL"},
 		},
+		{
+			// Escaped references produce literal text that is not interpolated, even when referenced by other env vars
+			inputEnvVars: map[string]string{
+				"APP_NAME":      "acme",
+				"HELM_TEMPLATE": "{{{{ .Values.image }}}}:{{ APP_NAME }}",
+				"LITERAL":       "{{{{ APP_NAME }}}}",
+				"LITERAL_COPY":  "copy of {{ LITERAL }}",
+				"LITERAL_REF":   "{{{{ secret(arn:aws:literal) }}}}",
+				"CONN_STRING":   "{{ DB_PREFIX }}{{{{ DATABASE_PASSWORD }}}}@{{ DATABASE_PASSWORD }}",
+				"DB_PREFIX":     "{{ APP_NAME }}:",
+				"UNKNOWN":       "{{ MISSING }}",
+			},
+			inputSecrets: map[string]string{
+				"DATABASE_PASSWORD": "fake-password",
+				"SECRET_TEMPLATE":   "{{{{ DATABASE_PASSWORD }}}}/{{ DATABASE_PASSWORD }}",
+			},
+			wantEnvVars: map[string]string{
+				"APP_NAME":      "acme",
+				"HELM_TEMPLATE": "{{ .Values.image }}:acme",
+				"LITERAL":       "{{ APP_NAME }}",
+				"LITERAL_COPY":  "copy of {{ APP_NAME }}",
+				"LITERAL_REF":   "{{ secret(arn:aws:literal) }}",
+				"DB_PREFIX":     "acme:",
+				"UNKNOWN":       "{{ MISSING }}",
+			},
+			wantSecrets: map[string]string{
+				"CONN_STRING":       "acme:{{ DATABASE_PASSWORD }}@fake-password",
+				"DATABASE_PASSWORD": "fake-password",
+				"SECRET_TEMPLATE":   "{{ DATABASE_PASSWORD }}/fake-password",
+			},
+			wantSecretRefs: map[string]string{},
+			wantSecretKeys: []string{"CONN_STRING", "DATABASE_PASSWORD", "SECRET_TEMPLATE"},
+		},
 	}
 
 	for i, test := range tests {
 		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
 			got := NewEnvVars(test.inputEnvVars, test.inputSecrets)
-			got.Interpolate()
+			require.NoError(t, got.Interpolate())
 			if diff := cmp.Diff(test.wantEnvVars, got.EnvVars()); diff != "" {
 				t.Errorf("mismatched env vars (-want, +got):\n%s", diff)
 			}
@@ -96,3 +135,333 @@ func TestMixedEnvVars_Interpolate(t *testing.T) {
 		})
 	}
 }
+
+func TestEnvVars_Cycles(t *testing.T) {
+	tests := []struct {
+		name         string
+		inputEnvVars map[string]string
+		inputSecrets map[string]string
+		want         [][]string
+	}{
+		{
+			name: "no cycles",
+			inputEnvVars: map[string]string{
+				"A": "{{ B }}/{{ C }}",
+				"B": "{{ C }}",
+				"C": "c",
+			},
+			want: [][]string{},
+		},
+		{
+			name: "mutual references",
+			inputEnvVars: map[string]string{
+				"A": "x{{ B }}",
+				"B": "y{{ A }}",
+			},
+			want: [][]string{{"A", "B", "A"}},
+		},
+		{
+			name: "self reference is left as-is",
+			inputEnvVars: map[string]string{
+				"PATH": "{{ PATH }}:{{ BIN }}",
+				"BIN":  "/bin",
+			},
+			want: [][]string{},
+		},
+		{
+			name: "referenced self reference",
+			inputEnvVars: map[string]string{
+				"A": "{{ A }}",
+				"B": "{{ A }}",
+			},
+			want: [][]string{{"A", "A"}},
+		},
+		{
+			name: "cycles through secrets",
+			inputEnvVars: map[string]string{
+				"A":     "{{ B }}",
+				"B":     "{{ TOKEN }}",
+				"OTHER": "{{ A }}",
+				"X":     "{{ Y }}",
+				"Y":     "{{ X }}",
+			},
+			inputSecrets: map[string]string{
+				"TOKEN": "{{ A }}",
+			},
+			want: [][]string{{"A", "B", "TOKEN", "A"}, {"X", "Y", "X"}},
+		},
+		{
+			name: "escaped references and secret refs do not form cycles",
+			inputEnvVars: map[string]string{
+				"A":   "{{{{ B }}}}",
+				"B":   "{{ A }}",
+				"REF": "{{ secret(arn:aws:{{ C }}) }}",
+				"C":   "{{ REF }}",
+			},
+			want: [][]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ev := NewEnvVars(test.inputEnvVars, test.inputSecrets)
+			if diff := cmp.Diff(test.want, ev.Cycles()); diff != "" {
+				t.Errorf("mismatched cycles (-want, +got):\n%s", diff)
+			}
+
+			err := ev.Interpolate()
+			if len(test.want) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var cycleErr *ErrInterpolationCycle
+			if assert.ErrorAs(t, err, &cycleErr) {
+				assert.Equal(t, test.want, cycleErr.Cycles)
+			}
+			// Nothing is interpolated when there is a cycle
+			if diff := cmp.Diff(NewEnvVars(test.inputEnvVars, test.inputSecrets), ev); diff != "" {
+				t.Errorf("env vars changed (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInterpolationCycleDiagnostics(t *testing.T) {
+	diags := interpolationCycleDiagnostics([][]string{{"B", "A", "B"}})
+	if assert.Len(t, diags, 1) {
+		assert.Equal(t, tfprotov5.DiagnosticSeverityError, diags[0].Severity)
+		assert.Equal(t, "Environment variables reference each other in a cycle: B -> A -> B", diags[0].Summary)
+		assert.Contains(t, diags[0].Detail, "The env variables (A, B) can never be fully interpolated.")
+	}
+}
+
+func TestTokenizeEnvVar(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []envVarToken
+	}{
+		{
+			name:  "empty",
+			value: "",
+			want:  []envVarToken{},
+		},
+		{
+			name:  "literal",
+			value: "postgres://localhost",
+			want:  []envVarToken{{Kind: literalToken, Text: "postgres://localhost"}},
+		},
+		{
+			name:  "references",
+			value: "{{A}}:{{ \tB_2\n}}/",
+			want: []envVarToken{
+				{Kind: refToken, Text: "{{A}}", Name: "A"},
+				{Kind: literalToken, Text: ":"},
+				{Kind: refToken, Text: "{{ \tB_2\n}}", Name: "B_2"},
+				{Kind: literalToken, Text: "/"},
+			},
+		},
+		{
+			name:  "extra braces",
+			value: "{{{ A }}}",
+			want: []envVarToken{
+				{Kind: literalToken, Text: "{"},
+				{Kind: refToken, Text: "{{ A }}", Name: "A"},
+				{Kind: literalToken, Text: "}"},
+			},
+		},
+		{
+			name:  "escaped references",
+			value: "{{{{ A }}}}{{{{{ secret(arn) }}}}}",
+			want: []envVarToken{
+				{Kind: escapedToken, Text: "{{ A }}"},
+				{Kind: escapedToken, Text: "{{{ secret(arn) }}"},
+				{Kind: literalToken, Text: "}"},
+			},
+		},
+		{
+			name:  "unterminated escape",
+			value: "{{{{ A }}",
+			want: []envVarToken{
+				{Kind: literalToken, Text: "{{"},
+				{Kind: refToken, Text: "{{ A }}", Name: "A"},
+			},
+		},
+		{
+			name:  "escape spanning lines",
+			value: "{{{{ A\n}}}}",
+			want: []envVarToken{
+				{Kind: literalToken, Text: "{{"},
+				{Kind: refToken, Text: "{{ A\n}}", Name: "A"},
+				{Kind: literalToken, Text: "}}"},
+			},
+		},
+		{
+			name:  "not references",
+			value: "{{ }} {{ A B }} {{ {A} }}",
+			want:  []envVarToken{{Kind: literalToken, Text: "{{ }} {{ A B }} {{ {A} }}"}},
+		},
+		{
+			name:  "names with punctuation",
+			value: "{{ APP-NAME }}{{ secret(arn) }}",
+			want: []envVarToken{
+				{Kind: refToken, Text: "{{ APP-NAME }}", Name: "APP-NAME"},
+				{Kind: refToken, Text: "{{ secret(arn) }}", Name: "secret(arn)"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, tokenizeEnvVar(test.value))
+		})
+	}
+}
+
+// TestEnvVars_Interpolate_Differential verifies that Interpolate produces the same output as interpolateLegacy
+// Inputs are acyclic because interpolateLegacy does not detect cycles
+// Inputs also never build a reference out of substituted text (e.g. `{{` + `FOO }}`)
+// because interpolateLegacy rescans substituted text and its result depends on map iteration order
+func TestEnvVars_Interpolate_Differential(t *testing.T) {
+	tests := map[string]EnvVars{}
+	for i, test := range []struct {
+		inputEnvVars map[string]string
+		inputSecrets map[string]string
+	}{
+		{
+			inputEnvVars: map[string]string{
+				"A": "{{ B }}-{{ C }}-{{ MISSING }}",
+				"B": "{{C}}{{ C }}",
+				"C": "c",
+			},
+		},
+		{
+			inputEnvVars: map[string]string{
+				"URL":     "postgres://{{ USER }}:{{ PASS }}@{{ HOST }}",
+				"USER":    "app",
+				"HOST":    "{{ REF }}",
+				"REF":     "{{ secret(arn:aws:{{ USER }}) }}",
+				"LITERAL": "{{{{ PASS }}}} {{{{ secret(arn:aws:literal) }}}}",
+				"COPY":    "{{ LITERAL }}/{{ URL }}",
+			},
+			inputSecrets: map[string]string{
+				"PASS":  "{{ USER }}-password",
+				"TOKEN": "{{ secret(arn:aws:token) }}/{{ PASS }}",
+			},
+		},
+		{
+			inputEnvVars: map[string]string{
+				"A": "{{{ B }}} {{{{{ B }}}}}",
+				"B": "{{{{ A }}}}",
+				"C": "{{{{ A }}\n}}}}",
+			},
+		},
+		{
+			inputEnvVars: map[string]string{
+				"PATH": "{{ PATH }}:{{ BIN }}",
+				"BIN":  "{{ HOME }}/bin",
+				"HOME": "/home/app",
+			},
+			inputSecrets: map[string]string{
+				"TOKEN": "{{TOKEN}}-{{ HOME }}",
+			},
+		},
+		{
+			inputEnvVars: map[string]string{
+				"APP-NAME": "{{ APP_ENV }}-api",
+				"APP_ENV":  "prod",
+				"LABEL":    "{{ APP-NAME }}",
+			},
+		},
+	} {
+		tests[fmt.Sprintf("%d", i)] = NewEnvVars(test.inputEnvVars, test.inputSecrets)
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		tests[fmt.Sprintf("random %d", i)] = randomEnvVars(r, 1+r.Intn(12))
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			want, got := maps.Clone(input), maps.Clone(input)
+			want.interpolateLegacy()
+			require.NoError(t, got.Interpolate())
+			if diff := cmp.Diff(want.EnvVars(), got.EnvVars()); diff != "" {
+				t.Errorf("mismatched env vars for %v (-want, +got):\n%s", input, diff)
+			}
+			if diff := cmp.Diff(want.Secrets(), got.Secrets()); diff != "" {
+				t.Errorf("mismatched secrets for %v (-want, +got):\n%s", input, diff)
+			}
+			if diff := cmp.Diff(want.SecretRefs(), got.SecretRefs()); diff != "" {
+				t.Errorf("mismatched secret refs for %v (-want, +got):\n%s", input, diff)
+			}
+		})
+	}
+}
+
+// randomEnvVars generates n acyclic env vars; each env var only references env vars generated before it
+func randomEnvVars(r *rand.Rand, n int) EnvVars {
+	literals := []string{"", "x", "db-1", " ", ":", "/", "a b", "\n"}
+	refFormats := []string{"{{ %s }}", "{{%s}}", "{{  %s\t}}", "{{{{ %s }}}}", "{{{{%s}}}}"}
+	keys := make([]string, 0, n)
+	envVars := EnvVars{}
+	for i := 0; i < n; i++ {
+		var sb strings.Builder
+		for j := r.Intn(5); j > 0; j-- {
+			switch roll := r.Intn(10); {
+			case roll < 4 && len(keys) > 0:
+				fmt.Fprintf(&sb, refFormats[r.Intn(len(refFormats))], keys[r.Intn(len(keys))])
+			case roll < 5:
+				fmt.Fprintf(&sb, refFormats[r.Intn(len(refFormats))], "MISSING")
+			case roll < 6:
+				sb.WriteString("{{ secret(arn:aws:" + literals[r.Intn(len(literals))] + ") }}")
+			default:
+				sb.WriteString(literals[r.Intn(len(literals))])
+			}
+		}
+		key := fmt.Sprintf("VAR_%d", i)
+		keys = append(keys, key)
+		envVars[key] = EnvVar{Value: sb.String(), IsSensitive: r.Intn(4) == 0}
+	}
+	return envVars
+}
+
+func BenchmarkEnvVars_Interpolate(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		input := benchmarkEnvVars(n)
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := maps.Clone(input).Interpolate(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEnvVars_InterpolateLegacy(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		input := benchmarkEnvVars(n)
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				maps.Clone(input).interpolateLegacy()
+			}
+		})
+	}
+}
+
+// benchmarkEnvVars generates n env vars where every 10th env var is a secret
+// and the others reference up to 2 earlier env vars (e.g. `VAR_6 = "{{ VAR_2 }}/{{ VAR_3 }}"`)
+func benchmarkEnvVars(n int) EnvVars {
+	envVars := EnvVars{}
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("VAR_%d", i)
+		switch {
+		case i%10 == 0:
+			envVars[key] = EnvVar{Value: fmt.Sprintf("secret-%d", i), IsSensitive: true}
+		default:
+			envVars[key] = EnvVar{Value: fmt.Sprintf("{{ VAR_%d }}/{{ VAR_%d }}", i/3, (i-1)/2)}
+		}
+	}
+	return envVars
+}
@@ -4,8 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/nullstone-io/terraform-provider-ns/ns"
-	"gopkg.in/nullstone-io/go-api-client.v0"
 	"gopkg.in/nullstone-io/go-api-client.v0/types"
 	"gopkg.in/nullstone-io/nullstone.v0/workspaces"
 	"log"
@@ -27,29 +25,74 @@ func (e *ErrViaConnectionNotFound) Error() string {
 	return fmt.Sprintf("via connection (%s) was not found in workspace %s", e.Via, e.Workspace.Id())
 }
 
+// ErrViaConnectionCycle occurs when a via connection returns to a workspace it already traversed
+// Path contains the source workspace name followed by each connection traversed (e.g. app -> cluster -> network -> cluster)
+type ErrViaConnectionCycle struct {
+	Via  string
+	Path []string
+}
+
+func (e *ErrViaConnectionCycle) Error() string {
+	return fmt.Sprintf("via connection (%s) revisits a workspace: %s", e.Via, strings.Join(e.Path, " -> "))
+}
+
+// ErrViaMaxHops occurs when a via connection traverses more connections than allowed
+type ErrViaMaxHops struct {
+	Via     string
+	MaxHops int
+}
+
+func (e *ErrViaMaxHops) Error() string {
+	return fmt.Sprintf("via connection (%s) traverses more than %d connections", e.Via, e.MaxHops)
+}
+
+// defaultMaxViaHops is the maximum number of connections a via connection can traverse unless the provider sets max_via_hops
+const defaultMaxViaHops = 10
+
 // walkViaConnection traverses one or many connections to retrieve the target workspace and its connections
 // If a via connection contains "/", it will use followViaConnection for each token separated by "/"
-func walkViaConnection(ctx context.Context, nsConfig api.Config, sourceWorkspace types.WorkspaceTarget, connections types.Connections, localConnections workspaces.ManifestConnections, via string) (types.WorkspaceTarget, types.Connections, error) {
+// sourceName is used to describe the source workspace in errors
+// If a workspace is visited twice, this returns ErrViaConnectionCycle before retrieving its connections again
+// If via contains more than maxHops connections (defaultMaxViaHops if maxHops <= 0), this returns ErrViaMaxHops
+func walkViaConnection(ctx context.Context, runConfigs *runConfigResolver, sourceWorkspace types.WorkspaceTarget, sourceName string, connections types.Connections, localConnections workspaces.ManifestConnections, via string, maxHops int) (types.WorkspaceTarget, types.Connections, error) {
+	if maxHops <= 0 {
+		maxHops = defaultMaxViaHops
+	}
+	hops := strings.Split(via, "/")
+	if len(hops) > maxHops {
+		return sourceWorkspace, connections, &ErrViaMaxHops{Via: via, MaxHops: maxHops}
+	}
+
 	curWorkspace, curConnections := sourceWorkspace, connections
-	for _, via := range strings.Split(via, "/") {
+	path := []string{sourceName}
+	visited := map[types.WorkspaceTarget]bool{sourceWorkspace: true}
+	for _, hop := range hops {
+		if next := findViaWorkspace(curWorkspace, curConnections, localConnections, hop); next != nil {
+			path = append(path, hop)
+			if visited[*next] {
+				return curWorkspace, curConnections, &ErrViaConnectionCycle{Via: via, Path: path}
+			}
+			visited[*next] = true
+		}
+
 		var err error
-		curWorkspace, curConnections, err = followViaConnection(ctx, nsConfig, curWorkspace, curConnections, localConnections, via)
+		curWorkspace, curConnections, err = followViaConnection(ctx, runConfigs, curWorkspace, curConnections, localConnections, hop)
 		if err != nil {
-			return curWorkspace, curConnections, fmt.Errorf("error traversing via %q: %w", via, err)
+			return curWorkspace, curConnections, fmt.Errorf("error traversing via %q: %w", hop, err)
 		}
 	}
 	return curWorkspace, curConnections, nil
 }
 
 // followViaConnection traverses a single connection to retrieve the target workspace and its connections
-func followViaConnection(ctx context.Context, nsConfig api.Config, sourceWorkspace types.WorkspaceTarget, connections types.Connections, localConnections workspaces.ManifestConnections, via string) (types.WorkspaceTarget, types.Connections, error) {
+func followViaConnection(ctx context.Context, runConfigs *runConfigResolver, sourceWorkspace types.WorkspaceTarget, connections types.Connections, localConnections workspaces.ManifestConnections, via string) (types.WorkspaceTarget, types.Connections, error) {
 	viaWorkspace := findViaWorkspace(sourceWorkspace, connections, localConnections, via)
 	if viaWorkspace == nil {
 		return sourceWorkspace, connections, &ErrViaConnectionNotFound{Workspace: sourceWorkspace, Via: via}
 	}
 
 	log.Printf("(followViaConnection) Pulling (via=%s) connections for %s", via, viaWorkspace.Id())
-	viaRunConfig, err := ns.GetWorkspaceConfig(ctx, nsConfig, *viaWorkspace)
+	viaRunConfig, err := runConfigs.GetRunConfig(ctx, *viaWorkspace)
 	if err != nil {
 		return sourceWorkspace, connections, fmt.Errorf("error retrieving connections for `via` workspace (via=%s, workspace=%s): %w", via, viaWorkspace.Id(), err)
 	}
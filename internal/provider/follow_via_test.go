@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+func TestWalkViaConnection(t *testing.T) {
+	allWorkspaces, runConfigs := mockConnectionGraph()
+	source := types.WorkspaceTarget{StackId: 100, BlockId: 101, EnvId: 102}
+
+	tests := []struct {
+		name          string
+		via           string
+		maxHops       int
+		wantWorkspace types.WorkspaceTarget
+		wantErr       string
+	}{
+		{
+			name:          "follows each connection",
+			via:           "cluster/network",
+			wantWorkspace: types.WorkspaceTarget{StackId: 100, BlockId: 105, EnvId: 102},
+		},
+		{
+			name:    "detects cycle",
+			via:     "cluster/network/cluster",
+			wantErr: "via connection (cluster/network/cluster) revisits a workspace: app -> cluster -> network -> cluster",
+		},
+		{
+			name:    "exceeds max hops",
+			via:     "cluster/network",
+			maxHops: 1,
+			wantErr: "via connection (cluster/network) traverses more than 1 connections",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			getNsConfig, closeNsFn := mockNs(mockNsServerWith(allWorkspaces, runConfigs))
+			defer closeNsFn()
+			nsConfig := getNsConfig()
+			nsConfig.OrgName = "org0"
+
+			runConfig := runConfigs[allWorkspaces[0].Uid.String()]
+			got, _, err := walkViaConnection(context.Background(), &runConfigResolver{NsConfig: nsConfig}, source, "app", runConfig.Connections, nil, test.via, test.maxHops)
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantWorkspace, got)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/agext/levenshtein"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+	"gopkg.in/nullstone-io/nullstone.v0/workspaces"
+)
+
+// missingConnection describes where a connection was searched for when it could not be found
+// It is used to explain a missing connection to the user
+type missingConnection struct {
+	Name string
+	// Workspace is the workspace whose connections were searched (after traversing Via)
+	Workspace types.WorkspaceTarget
+	Via       string
+	// Reason explains why the connections could not be searched (e.g. a via connection was not found)
+	Reason string
+	// CapabilityName is set if the searched connections are scoped to a capability
+	CapabilityName string
+	// Searched contains the names of the connections that were searched
+	Searched []string
+	// Unconfigured is true if the connection exists, but does not target a workspace yet
+	Unconfigured bool
+	// Local contains the names of the local connections in the plan config
+	Local []string
+	// App contains the names of the application's connections when CapabilityName is set
+	App []string
+	// Capabilities contains the names of the connections of every other capability, keyed by capability name
+	Capabilities map[string][]string
+}
+
+func newMissingConnection(name string, workspace types.WorkspaceTarget, connections types.Connections, localConnections workspaces.ManifestConnections) *missingConnection {
+	m := &missingConnection{
+		Name:      name,
+		Workspace: workspace,
+		Searched:  sortedConnectionNames(connections),
+	}
+	if conn, ok := connections[name]; ok && conn.EffectiveTarget == nil {
+		m.Unconfigured = true
+	}
+	for localName := range localConnections {
+		m.Local = append(m.Local, localName)
+	}
+	sort.Strings(m.Local)
+	return m
+}
+
+// addCapabilities records the connections of the application and capabilities not searched
+// This allows Detail to explain when capability_name filtered out a connection
+func (m *missingConnection) addCapabilities(runConfig *types.RunConfig, capabilityName string) {
+	if runConfig == nil {
+		return
+	}
+	m.CapabilityName = capabilityName
+	if capabilityName != "" {
+		m.App = sortedConnectionNames(runConfig.Connections)
+	}
+	for _, capability := range runConfig.Capabilities {
+		if capability.Name == capabilityName {
+			continue
+		}
+		if m.Capabilities == nil {
+			m.Capabilities = map[string][]string{}
+		}
+		m.Capabilities[capability.Name] = sortedConnectionNames(capability.Connections)
+	}
+}
+
+// Detail explains why the connection is missing, suggests a similar connection name, and lists the available connections
+func (m *missingConnection) Detail() string {
+	lines := make([]string, 0)
+	if m.Reason != "" {
+		lines = append(lines, m.Reason)
+	}
+	switch {
+	case m.Unconfigured:
+		lines = append(lines, fmt.Sprintf("The connection %q exists in workspace %s, but is not connected to another workspace yet.", m.Name, m.Workspace.Id()))
+	case m.Reason == "" && m.Via != "":
+		lines = append(lines, fmt.Sprintf("Workspace %s (via %s) has no connection named %q.", m.Workspace.Id(), m.Via, m.Name))
+	case m.Reason == "":
+		lines = append(lines, fmt.Sprintf("Workspace %s has no connection named %q.", m.Workspace.Id(), m.Name))
+	}
+
+	if suggestion := suggestConnectionName(m.Name, append(append([]string{}, m.Searched...), m.Local...)); suggestion != "" {
+		lines = append(lines, fmt.Sprintf("Did you mean %q?", suggestion))
+	}
+	if m.CapabilityName != "" && containsString(m.App, m.Name) {
+		lines = append(lines, fmt.Sprintf("The application has a connection named %q, but capability_name = %q scopes connections to the capability. Use ns_app_connection to read the application's connections.", m.Name, m.CapabilityName))
+	}
+	capabilityNames := make([]string, 0, len(m.Capabilities))
+	for capabilityName := range m.Capabilities {
+		capabilityNames = append(capabilityNames, capabilityName)
+	}
+	sort.Strings(capabilityNames)
+	for _, capabilityName := range capabilityNames {
+		if containsString(m.Capabilities[capabilityName], m.Name) {
+			lines = append(lines, fmt.Sprintf("Capability %q has a connection named %q. Set capability_name = %q in the provider to read the capability's connections.", capabilityName, m.Name, capabilityName))
+		}
+	}
+
+	if m.Reason == "" {
+		if m.CapabilityName != "" {
+			lines = append(lines, fmt.Sprintf("Connections of capability %q: %s", m.CapabilityName, formatConnectionNames(m.Searched)))
+		} else {
+			lines = append(lines, fmt.Sprintf("Connections: %s", formatConnectionNames(m.Searched)))
+		}
+	}
+	if len(m.Local) > 0 {
+		lines = append(lines, fmt.Sprintf("Local connections (plan config): %s", formatConnectionNames(m.Local)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// suggestConnectionName returns the candidate closest to name by edit distance
+// If no candidate is close enough to be a likely typo, this returns ""
+func suggestConnectionName(name string, candidates []string) string {
+	maxDistance := len(name) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	best, bestDistance := "", maxDistance+1
+	for _, candidate := range candidates {
+		if candidate == name {
+			continue
+		}
+		distance := levenshtein.Distance(name, candidate, nil)
+		if distance < bestDistance || (distance == bestDistance && candidate < best) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+func sortedConnectionNames(connections types.Connections) []string {
+	names := make([]string, 0, len(connections))
+	for name := range connections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func formatConnectionNames(names []string) string {
+	if len(names) == 0 {
+		return "(none)"
+	}
+	return strings.Join(names, ", ")
+}
+
+func containsString(s []string, value string) bool {
+	for _, cur := range s {
+		if cur == value {
+			return true
+		}
+	}
+	return false
+}
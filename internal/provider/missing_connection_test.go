@@ -0,0 +1,125 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/nullstone-io/module/config"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+	"gopkg.in/nullstone-io/nullstone.v0/workspaces"
+)
+
+func TestSuggestConnectionName(t *testing.T) {
+	candidates := []string{"cluster", "network", "postgres", "postgres-replica"}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "postgress", want: "postgres"},
+		{name: "netwrok", want: "network"},
+		{name: "clustr", want: "cluster"},
+		{name: "redis", want: ""},
+		{name: "cluster", want: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, suggestConnectionName(test.name, candidates))
+		})
+	}
+}
+
+func TestMissingConnection_Detail(t *testing.T) {
+	workspace := types.WorkspaceTarget{StackId: 100, BlockId: 101, EnvId: 102}
+	connection := func(blockId int64) types.Connection {
+		conn := types.Connection{Connection: config.Connection{Contract: "datastore/aws/postgres:rds"}}
+		if blockId != 0 {
+			conn.EffectiveTarget = &types.ConnectionTarget{StackId: 100, BlockId: blockId}
+		}
+		return conn
+	}
+	runConfig := &types.RunConfig{
+		WorkspaceConfig: types.WorkspaceConfig{
+			Connections: types.Connections{
+				"cluster":  connection(103),
+				"postgres": connection(104),
+			},
+			Capabilities: types.CapabilityConfigs{
+				{Name: "datadog", Connections: types.Connections{"datadog": connection(105)}},
+				{Name: "redis", Connections: types.Connections{"redis": connection(106)}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		missing func() *missingConnection
+		want    string
+	}{
+		{
+			name: "suggests a close match",
+			missing: func() *missingConnection {
+				m := newMissingConnection("postgress", workspace, runConfig.Connections, nil)
+				m.addCapabilities(runConfig, "")
+				return m
+			},
+			want: `Workspace 100/101/102 has no connection named "postgress".
+Did you mean "postgres"?
+Connections: cluster, postgres`,
+		},
+		{
+			name: "suggests local connections",
+			missing: func() *missingConnection {
+				return newMissingConnection("netwrok", workspace, runConfig.Connections, workspaces.ManifestConnections{"network": {BlockId: 105}})
+			},
+			want: `Workspace 100/101/102 has no connection named "netwrok".
+Did you mean "network"?
+Connections: cluster, postgres
+Local connections (plan config): network`,
+		},
+		{
+			name: "explains capability connections",
+			missing: func() *missingConnection {
+				m := newMissingConnection("redis", workspace, runConfig.Connections, nil)
+				m.addCapabilities(runConfig, "")
+				return m
+			},
+			want: `Workspace 100/101/102 has no connection named "redis".
+Capability "redis" has a connection named "redis". Set capability_name = "redis" in the provider to read the capability's connections.
+Connections: cluster, postgres`,
+		},
+		{
+			name: "explains capability_name filtered out the application's connection",
+			missing: func() *missingConnection {
+				m := newMissingConnection("postgres", workspace, runConfig.Capabilities[0].Connections, nil)
+				m.addCapabilities(runConfig, "datadog")
+				return m
+			},
+			want: `Workspace 100/101/102 has no connection named "postgres".
+The application has a connection named "postgres", but capability_name = "datadog" scopes connections to the capability. Use ns_app_connection to read the application's connections.
+Connections of capability "datadog": datadog`,
+		},
+		{
+			name: "connection without target",
+			missing: func() *missingConnection {
+				return newMissingConnection("mysql", workspace, types.Connections{"mysql": connection(0)}, nil)
+			},
+			want: `The connection "mysql" exists in workspace 100/101/102, but is not connected to another workspace yet.
+Connections: mysql`,
+		},
+		{
+			name: "via connection not found",
+			missing: func() *missingConnection {
+				m := newMissingConnection("network", workspace, nil, nil)
+				m.Via, m.Reason = "cluster", "via connection (cluster) was not found in workspace 100/101/102"
+				return m
+			},
+			want: `via connection (cluster) was not found in workspace 100/101/102`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, test.missing().Detail())
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+// stalenessPolicy configures when the outputs of a connected workspace are considered stale
+type stalenessPolicy struct {
+	// MaxAge is the maximum age of the connected workspace's state, 0 disables the check
+	MaxAge time.Duration
+	// RequireSuccessfulRun requires that the last finished run of the connected workspace did not fail
+	RequireSuccessfulRun bool
+}
+
+// maxOutputAgeAttribute is the `max_output_age` attribute shared by data sources that read a single connection
+func maxOutputAgeAttribute() *tfprotov5.SchemaAttribute {
+	return &tfprotov5.SchemaAttribute{
+		Name:     "max_output_age",
+		Type:     tftypes.String,
+		Optional: true,
+		Description: `The maximum age of the connected workspace's state (e.g. ` + "`12h`, `7d`" + `).
+If the state was last updated longer ago, this data source emits a warning (an error if ` + "`strict`" + `).`,
+		DescriptionKind: tfprotov5.StringKindMarkdown,
+	}
+}
+
+// requireSuccessfulRunAttribute is the `require_successful_run` attribute shared by data sources that read a single connection
+func requireSuccessfulRunAttribute() *tfprotov5.SchemaAttribute {
+	return &tfprotov5.SchemaAttribute{
+		Name:     "require_successful_run",
+		Type:     tftypes.Bool,
+		Optional: true,
+		Description: `Require that the last finished run of the connected workspace did not fail.
+If it failed, this data source emits a warning (an error if ` + "`strict`" + `) because the outputs may be stale.`,
+		DescriptionKind: tfprotov5.StringKindMarkdown,
+	}
+}
+
+// parseStalenessPolicy parses `max_output_age` and `require_successful_run`
+func parseStalenessPolicy(config map[string]tftypes.Value) (stalenessPolicy, []*tfprotov5.Diagnostic) {
+	diags := make([]*tfprotov5.Diagnostic, 0)
+	policy := stalenessPolicy{RequireSuccessfulRun: extractBoolFromConfig(config, "require_successful_run")}
+	if raw := extractStringFromConfig(config, "max_output_age"); raw != "" {
+		maxAge, err := parseMaxOutputAge(raw)
+		if err != nil {
+			diags = append(diags, &tfprotov5.Diagnostic{
+				Severity: tfprotov5.DiagnosticSeverityError,
+				Summary:  fmt.Sprintf("max_output_age (%s) is invalid", raw),
+				Detail:   err.Error(),
+			})
+		}
+		policy.MaxAge = maxAge
+	}
+	return policy, diags
+}
+
+// parseMaxOutputAge parses a duration like time.ParseDuration, but also accepts a whole number of days (e.g. `7d`)
+func parseMaxOutputAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("expected a positive whole number of days (e.g. 7d)")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("expected a duration (e.g. 12h, 90m) or a number of days (e.g. 7d)")
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be greater than 0")
+	}
+	return d, nil
+}
+
+// Check reports connection outputs that are stale according to the policy
+// Outputs that could not be read are not checked, they are already reported by readWorkspaceOutputs
+func (p stalenessPolicy) Check(workspaceId string, outputs workspaceOutputs, now time.Time, strict bool) []*tfprotov5.Diagnostic {
+	diags := make([]*tfprotov5.Diagnostic, 0)
+	if outputs.Workspace == nil || outputs.StateFile == nil {
+		return diags
+	}
+	severity := tfprotov5.DiagnosticSeverityWarning
+	if strict {
+		severity = tfprotov5.DiagnosticSeverityError
+	}
+
+	if p.MaxAge > 0 {
+		updatedAt := outputs.StateFile.UpdatedAt
+		if updatedAt.IsZero() {
+			diags = append(diags, &tfprotov5.Diagnostic{
+				Severity: severity,
+				Summary:  fmt.Sprintf("Unable to determine the age of the outputs of workspace %q.", workspaceId),
+				Detail:   "The state source did not report when the state was last updated.",
+			})
+		} else if age := now.Sub(updatedAt); age > p.MaxAge {
+			diags = append(diags, &tfprotov5.Diagnostic{
+				Severity: severity,
+				Summary:  fmt.Sprintf("The outputs of workspace %q are older than max_output_age (%s).", workspaceId, p.MaxAge),
+				Detail:   fmt.Sprintf("The state was last updated at %s (%s ago).", updatedAt.UTC().Format(time.RFC3339), age.Round(time.Minute)),
+			})
+		}
+	}
+
+	if p.RequireSuccessfulRun {
+		if run := outputs.Workspace.LastFinishedRun; run == nil {
+			diags = append(diags, &tfprotov5.Diagnostic{
+				Severity: severity,
+				Summary:  fmt.Sprintf("Unable to determine the last run of workspace %q.", workspaceId),
+				Detail:   "The workspace did not report its last finished run (e.g. when the outputs are read from state_dir).",
+			})
+		} else if run.Status == types.RunStatusFailed {
+			detail := fmt.Sprintf("The last run (%s) failed; the outputs may not reflect the configuration of the workspace.", run.Uid)
+			if run.StatusMessage != "" {
+				detail += "\n" + run.StatusMessage
+			}
+			diags = append(diags, &tfprotov5.Diagnostic{
+				Severity: severity,
+				Summary:  fmt.Sprintf("The last run of workspace %q failed.", workspaceId),
+				Detail:   detail,
+			})
+		}
+	}
+	return diags
+}
@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/nullstone-io/terraform-provider-ns/ns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+func TestParseMaxOutputAge(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{input: "12h", want: 12 * time.Hour},
+		{input: "90m", want: 90 * time.Minute},
+		{input: "7d", want: 7 * 24 * time.Hour},
+		{input: "0d", wantErr: true},
+		{input: "1.5d", wantErr: true},
+		{input: "-1h", wantErr: true},
+		{input: "week", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got, err := parseMaxOutputAge(test.input)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestStalenessPolicy_Check(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	failedRun := &types.Run{Status: types.RunStatusFailed, StatusMessage: "error creating subnet"}
+	completedRun := &types.Run{Status: types.RunStatusCompleted}
+	outputs := func(updatedAt time.Time, lastRun *types.Run) workspaceOutputs {
+		return workspaceOutputs{
+			Workspace: &types.Workspace{StackId: 100, BlockId: 105, EnvId: 102, LastFinishedRun: lastRun},
+			StateFile: &ns.StateFile{UpdatedAt: updatedAt},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		policy       stalenessPolicy
+		outputs      workspaceOutputs
+		strict       bool
+		wantSummary  []string
+		wantSeverity tfprotov5.DiagnosticSeverity
+	}{
+		{
+			name:    "no policy",
+			outputs: outputs(now.Add(-30*24*time.Hour), failedRun),
+		},
+		{
+			name:    "fresh outputs",
+			policy:  stalenessPolicy{MaxAge: 24 * time.Hour, RequireSuccessfulRun: true},
+			outputs: outputs(now.Add(-time.Hour), completedRun),
+		},
+		{
+			name:         "outputs older than max age",
+			policy:       stalenessPolicy{MaxAge: 24 * time.Hour},
+			outputs:      outputs(now.Add(-48*time.Hour), nil),
+			wantSummary:  []string{`The outputs of workspace "100/105/102" are older than max_output_age (24h0m0s).`},
+			wantSeverity: tfprotov5.DiagnosticSeverityWarning,
+		},
+		{
+			name:         "unknown age",
+			policy:       stalenessPolicy{MaxAge: 24 * time.Hour},
+			outputs:      outputs(time.Time{}, nil),
+			wantSummary:  []string{`Unable to determine the age of the outputs of workspace "100/105/102".`},
+			wantSeverity: tfprotov5.DiagnosticSeverityWarning,
+		},
+		{
+			name:         "failed run in strict mode",
+			policy:       stalenessPolicy{RequireSuccessfulRun: true},
+			outputs:      outputs(now, failedRun),
+			strict:       true,
+			wantSummary:  []string{`The last run of workspace "100/105/102" failed.`},
+			wantSeverity: tfprotov5.DiagnosticSeverityError,
+		},
+		{
+			name:         "unknown last run",
+			policy:       stalenessPolicy{RequireSuccessfulRun: true},
+			outputs:      outputs(now, nil),
+			wantSummary:  []string{`Unable to determine the last run of workspace "100/105/102".`},
+			wantSeverity: tfprotov5.DiagnosticSeverityWarning,
+		},
+		{
+			name:    "outputs not read",
+			policy:  stalenessPolicy{MaxAge: time.Hour, RequireSuccessfulRun: true},
+			outputs: workspaceOutputs{Workspace: &types.Workspace{LastFinishedRun: failedRun}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diags := test.policy.Check("100/105/102", test.outputs, now, test.strict)
+			gotSummary := make([]string, 0)
+			for _, diag := range diags {
+				gotSummary = append(gotSummary, diag.Summary)
+				assert.Equal(t, test.wantSeverity, diag.Severity)
+			}
+			if test.wantSummary == nil {
+				test.wantSummary = []string{}
+			}
+			assert.Equal(t, test.wantSummary, gotSummary)
+		})
+	}
+}
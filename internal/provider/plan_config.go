@@ -2,12 +2,15 @@ package provider
 
 import (
 	"encoding/json"
+	"fmt"
 	"gopkg.in/nullstone-io/go-api-client.v0/types"
 	"gopkg.in/nullstone-io/nullstone.v0/workspaces"
 	"gopkg.in/yaml.v3"
 	"os"
 	"path"
+	"reflect"
 	"strconv"
+	"strings"
 )
 
 var (
@@ -15,6 +18,25 @@ var (
 	nullstoneJsonFilename      = ".nullstone.json"
 )
 
+const (
+	// PlanConfigSourceProvider indicates a plan config value was set in the provider block
+	PlanConfigSourceProvider = "provider"
+	// planConfigConnectionsKey is the yaml key for local connections
+	planConfigConnectionsKey = "connections"
+)
+
+// planConfigEnvVars maps each plan config field (by yaml key) to the environment variable that configures it
+var planConfigEnvVars = map[string]string{
+	"org_name":   "NULLSTONE_ORG_NAME",
+	"stack_id":   "NULLSTONE_STACK_ID",
+	"stack_name": "NULLSTONE_STACK_NAME",
+	"block_id":   "NULLSTONE_BLOCK_ID",
+	"block_name": "NULLSTONE_BLOCK_NAME",
+	"block_ref":  "NULLSTONE_BLOCK_REF",
+	"env_id":     "NULLSTONE_ENV_ID",
+	"env_name":   "NULLSTONE_ENV_NAME",
+}
+
 type PlanConfig workspaces.Manifest
 
 func (c PlanConfig) WorkspaceTarget() types.WorkspaceTarget {
@@ -25,33 +47,114 @@ func (c PlanConfig) WorkspaceTarget() types.WorkspaceTarget {
 	}
 }
 
+// PlanConfigProvenance records where each plan config value came from
+// The key is the yaml key of the field (e.g. `stack_id`); local connections are keyed by `connections.<name>`
+// The value is the source (e.g. `env:NULLSTONE_STACK_ID`, `.nullstone/active-workspace.yml`, `provider`)
+// Fields that were not set by any source are omitted
+type PlanConfigProvenance map[string]string
+
 // LoadPlanConfig loads nullstone context for the current workspace
-// Originally, this was in a file named `.nullstone.json`, but moved to `.nullstone/active-workspace.yml`
-// As a result, this function will attempt the following:
-//  1. Load `.nullstone/active-workspace.yml`
-//  2. If not found, load `.nullstone.json`
-//  3. Fall back to environment variables for each attribute
+// See LoadLayeredPlanConfig for details
 func LoadPlanConfig() (PlanConfig, error) {
-	c := planConfigFromEnv()
-
-	// Attempt to load .nullstone/active-workspace.yml
-	if file, err := os.Open(activeWorkspaceYmlFilename); err == nil {
-		decoder := yaml.NewDecoder(file)
-		err2 := decoder.Decode(&c)
-		file.Close()
-		return c, err2
+	c, _, err := LoadLayeredPlanConfig()
+	return c, err
+}
+
+// LoadLayeredPlanConfig loads nullstone context for the current workspace by merging the following layers
+// Each layer overrides non-empty values from the previous layers:
+//  1. Environment variables (e.g. NULLSTONE_STACK_ID)
+//  2. `.nullstone.json` (the original location of the plan config)
+//  3. `.nullstone/active-workspace.yml`
+//
+// Provider attributes are layered on top during Configure (see PlanConfig.Override)
+// The returned provenance records which layer supplied each value
+func LoadLayeredPlanConfig() (PlanConfig, PlanConfigProvenance, error) {
+	result := PlanConfig{}
+	provenance := PlanConfigProvenance{}
+
+	fromEnv := planConfigFromEnv()
+	result.merge(fromEnv, provenance, func(key string) string {
+		return "env:" + planConfigEnvVars[key]
+	})
+
+	if fromJson, ok, err := planConfigFromFile(nullstoneJsonFilename, func(file *os.File, c *PlanConfig) error {
+		return json.NewDecoder(file).Decode(c)
+	}); err != nil {
+		return result, provenance, err
+	} else if ok {
+		result.merge(fromJson, provenance, constSource(nullstoneJsonFilename))
 	}
 
-	// Attempt to load .nullstone.json
-	if file, err := os.Open(nullstoneJsonFilename); err == nil {
-		decoder := json.NewDecoder(file)
-		err2 := decoder.Decode(&c)
-		file.Close()
-		return c, err2
+	if fromYml, ok, err := planConfigFromFile(activeWorkspaceYmlFilename, func(file *os.File, c *PlanConfig) error {
+		return yaml.NewDecoder(file).Decode(c)
+	}); err != nil {
+		return result, provenance, err
+	} else if ok {
+		result.merge(fromYml, provenance, constSource(activeWorkspaceYmlFilename))
 	}
 
-	// Just rely on config from env if no plan config files
-	return c, nil
+	return result, provenance, nil
+}
+
+// Override layers other on top of c, recording source for every value that was overridden
+func (c *PlanConfig) Override(other PlanConfig, provenance PlanConfigProvenance, source string) {
+	c.merge(other, provenance, constSource(source))
+}
+
+// merge copies every non-empty field (and each local connection) from other into c
+func (c *PlanConfig) merge(other PlanConfig, provenance PlanConfigProvenance, sourceFn func(key string) string) {
+	dst := reflect.ValueOf(c).Elem()
+	src := reflect.ValueOf(other)
+	for i := 0; i < src.NumField(); i++ {
+		field := src.Type().Field(i)
+		key := planConfigFieldKey(field)
+		if key == planConfigConnectionsKey {
+			continue
+		}
+		if val := src.Field(i); !val.IsZero() {
+			dst.Field(i).Set(val)
+			provenance[key] = sourceFn(key)
+		}
+	}
+
+	for name, conn := range other.Connections {
+		if c.Connections == nil {
+			c.Connections = workspaces.ManifestConnections{}
+		}
+		c.Connections[name] = conn
+		key := fmt.Sprintf("%s.%s", planConfigConnectionsKey, name)
+		provenance[key] = sourceFn(key)
+	}
+}
+
+func planConfigFieldKey(field reflect.StructField) string {
+	if tag := field.Tag.Get("yaml"); tag != "" {
+		return strings.Split(tag, ",")[0]
+	}
+	return field.Name
+}
+
+func constSource(source string) func(key string) string {
+	return func(key string) string {
+		return source
+	}
+}
+
+// planConfigFromFile decodes a plan config file if it exists
+// This returns false if the file does not exist
+func planConfigFromFile(filename string, decode func(file *os.File, c *PlanConfig) error) (PlanConfig, bool, error) {
+	c := PlanConfig{}
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return c, false, nil
+	} else if err != nil {
+		return c, false, err
+	}
+	defer file.Close()
+	if err := decode(file, &c); err != nil {
+		return c, true, fmt.Errorf("error reading %s: %w", filename, err)
+	}
+	return c, true, nil
 }
 
 func planConfigFromEnv() PlanConfig {
@@ -76,3 +179,39 @@ func readIntFromEnvVars(name string) int64 {
 	}
 	return 0
 }
+
+// OverrideWorkspace replaces the workspace target of the plan config with the provider's `stack`, `block`, and `env` attributes
+// Each value may be an ID or a name; the other identifier is cleared so that it is resolved from the override
+// Local connections describe the original workspace, so they are dropped when any part of the workspace is overridden
+func (c *PlanConfig) OverrideWorkspace(stack, block, env string, provenance PlanConfigProvenance) {
+	if stack == "" && block == "" && env == "" {
+		return
+	}
+	overrideIdOrName := func(val string, kind string, id *int64, name *string) {
+		if val == "" {
+			return
+		}
+		idKey, nameKey := kind+"_id", kind+"_name"
+		delete(provenance, idKey)
+		delete(provenance, nameKey)
+		if parsed, err := strconv.ParseInt(val, 10, 64); err == nil {
+			*id, *name = parsed, ""
+			provenance[idKey] = PlanConfigSourceProvider
+		} else {
+			*id, *name = 0, val
+			provenance[nameKey] = PlanConfigSourceProvider
+		}
+	}
+	overrideIdOrName(stack, "stack", &c.StackId, &c.StackName)
+	overrideIdOrName(block, "block", &c.BlockId, &c.BlockName)
+	overrideIdOrName(env, "env", &c.EnvId, &c.EnvName)
+	if block != "" {
+		c.BlockRef = ""
+		delete(provenance, "block_ref")
+	}
+
+	for name := range c.Connections {
+		delete(provenance, fmt.Sprintf("%s.%s", planConfigConnectionsKey, name))
+	}
+	c.Connections = nil
+}
@@ -3,7 +3,9 @@ package provider
 import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/nullstone-io/nullstone.v0/workspaces"
 	"os"
+	"path"
 	"testing"
 )
 
@@ -25,3 +27,149 @@ func TestLoadPlanConfig(t *testing.T) {
 	}
 	assert.Equal(t, want, got)
 }
+
+func TestLoadLayeredPlanConfig(t *testing.T) {
+	writeFile := func(t *testing.T, filename, content string) {
+		require.NoError(t, os.MkdirAll(path.Dir(filename), 0755))
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+	}
+
+	tests := []struct {
+		name           string
+		env            map[string]string
+		files          map[string]string
+		want           PlanConfig
+		wantProvenance PlanConfigProvenance
+	}{
+		{
+			name: "env only",
+			env: map[string]string{
+				"NULLSTONE_STACK_ID":   "100",
+				"NULLSTONE_STACK_NAME": "demo",
+			},
+			want: PlanConfig{StackId: 100, StackName: "demo"},
+			wantProvenance: PlanConfigProvenance{
+				"stack_id":   "env:NULLSTONE_STACK_ID",
+				"stack_name": "env:NULLSTONE_STACK_NAME",
+			},
+		},
+		{
+			name: "partial yaml layers over env and json",
+			env: map[string]string{
+				"NULLSTONE_ORG_NAME": "nullstone",
+				"NULLSTONE_ENV_ID":   "1",
+			},
+			files: map[string]string{
+				".nullstone.json": `{"stackId": 100, "envId": 2, "envName": "dev"}`,
+				".nullstone/active-workspace.yml": `env_id: 3
+connections:
+  network:
+    block_id: 200
+`,
+			},
+			want: PlanConfig{
+				OrgName: "nullstone",
+				StackId: 100,
+				EnvId:   3,
+				EnvName: "dev",
+				Connections: workspaces.ManifestConnections{
+					"network": {BlockId: 200},
+				},
+			},
+			wantProvenance: PlanConfigProvenance{
+				"org_name":            "env:NULLSTONE_ORG_NAME",
+				"stack_id":            ".nullstone.json",
+				"env_id":              ".nullstone/active-workspace.yml",
+				"env_name":            ".nullstone.json",
+				"connections.network": ".nullstone/active-workspace.yml",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range planConfigEnvVars {
+				t.Setenv(name, "")
+			}
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+			original, _ := os.Getwd()
+			require.NoError(t, os.Chdir(t.TempDir()))
+			defer os.Chdir(original)
+			for filename, content := range test.files {
+				writeFile(t, filename, content)
+			}
+
+			got, gotProvenance, err := LoadLayeredPlanConfig()
+			require.NoError(t, err, "unexpected error")
+			assert.Equal(t, test.want, got)
+			assert.Equal(t, test.wantProvenance, gotProvenance)
+		})
+	}
+}
+
+func TestPlanConfig_Override(t *testing.T) {
+	c := PlanConfig{OrgName: "nullstone", StackId: 100}
+	provenance := PlanConfigProvenance{"org_name": "env:NULLSTONE_ORG_NAME", "stack_id": ".nullstone.json"}
+	c.Override(PlanConfig{OrgName: "acme"}, provenance, PlanConfigSourceProvider)
+	assert.Equal(t, PlanConfig{OrgName: "acme", StackId: 100}, c)
+	assert.Equal(t, PlanConfigProvenance{"org_name": "provider", "stack_id": ".nullstone.json"}, provenance)
+}
+
+func TestPlanConfig_OverrideWorkspace(t *testing.T) {
+	original := PlanConfig{
+		OrgName:   "nullstone",
+		StackId:   100,
+		StackName: "demo",
+		BlockId:   101,
+		BlockName: "fargate0",
+		BlockRef:  "yellow-giraffe",
+		EnvId:     102,
+		EnvName:   "dev",
+		Connections: workspaces.ManifestConnections{
+			"cluster": {BlockId: 103},
+		},
+	}
+	originalProvenance := func() PlanConfigProvenance {
+		return PlanConfigProvenance{
+			"org_name":            ".nullstone/active-workspace.yml",
+			"stack_id":            ".nullstone/active-workspace.yml",
+			"stack_name":          ".nullstone/active-workspace.yml",
+			"block_id":            ".nullstone/active-workspace.yml",
+			"block_name":          ".nullstone/active-workspace.yml",
+			"block_ref":           ".nullstone/active-workspace.yml",
+			"env_id":              ".nullstone/active-workspace.yml",
+			"env_name":            ".nullstone/active-workspace.yml",
+			"connections.cluster": ".nullstone/active-workspace.yml",
+		}
+	}
+
+	t.Run("no overrides", func(t *testing.T) {
+		c, provenance := original, originalProvenance()
+		c.OverrideWorkspace("", "", "", provenance)
+		assert.Equal(t, original, c)
+		assert.Equal(t, originalProvenance(), provenance)
+	})
+
+	t.Run("overrides by id and name", func(t *testing.T) {
+		c, provenance := original, originalProvenance()
+		c.OverrideWorkspace("", "cluster0", "200", provenance)
+		want := PlanConfig{
+			OrgName:   "nullstone",
+			StackId:   100,
+			StackName: "demo",
+			BlockName: "cluster0",
+			EnvId:     200,
+		}
+		wantProvenance := PlanConfigProvenance{
+			"org_name":   ".nullstone/active-workspace.yml",
+			"stack_id":   ".nullstone/active-workspace.yml",
+			"stack_name": ".nullstone/active-workspace.yml",
+			"block_name": "provider",
+			"env_id":     "provider",
+		}
+		assert.Equal(t, want, c)
+		assert.Equal(t, wantProvenance, provenance)
+	})
+}
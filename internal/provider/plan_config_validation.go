@@ -0,0 +1,218 @@
+package provider
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"gopkg.in/nullstone-io/nullstone.v0/workspaces"
+	"gopkg.in/yaml.v3"
+)
+
+// PlanConfigIssue is a problem found while validating a plan config file
+type PlanConfigIssue struct {
+	Filename string
+	// Line is 0 if the issue does not refer to a specific line
+	Line     int
+	Severity tfprotov5.DiagnosticSeverity
+	Message  string
+}
+
+func (i PlanConfigIssue) Diagnostic() *tfprotov5.Diagnostic {
+	location := i.Filename
+	if i.Line > 0 {
+		location = fmt.Sprintf("%s:%d", i.Filename, i.Line)
+	}
+	return &tfprotov5.Diagnostic{
+		Severity: i.Severity,
+		Summary:  fmt.Sprintf("Invalid plan config (%s)", location),
+		Detail:   i.Message,
+	}
+}
+
+// ValidatePlanConfigFile performs strict validation of a plan config file
+// This supports yaml (e.g. `.nullstone/active-workspace.yml`) and json (e.g. `.nullstone.json`) based on the file extension
+// Unknown keys are reported as warnings
+// Malformed files, values of the wrong type, and local connections that don't reference a block are reported as errors
+// If the file does not exist, this returns no issues
+func ValidatePlanConfigFile(filename string) ([]PlanConfigIssue, error) {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if filepath.Ext(filename) == ".json" {
+		return validatePlanConfigJson(filename, file), nil
+	}
+	return validatePlanConfigYaml(filename, file), nil
+}
+
+func validatePlanConfigYaml(filename string, r io.Reader) []PlanConfigIssue {
+	return validatePlanConfig(planConfigValidator{filename: filename, tag: "yaml"}, r)
+}
+
+// validatePlanConfigJson validates a json plan config with the json keys of PlanConfig (e.g. `stackId`)
+// json is parsed as yaml (a superset of json) to report line numbers; keys are matched case-insensitively like encoding/json
+func validatePlanConfigJson(filename string, r io.Reader) []PlanConfigIssue {
+	return validatePlanConfig(planConfigValidator{filename: filename, tag: "json", foldKeys: true}, r)
+}
+
+func validatePlanConfig(v planConfigValidator, r io.Reader) []PlanConfigIssue {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err == io.EOF {
+		return nil
+	} else if err != nil {
+		v.errorf(nil, "%s", err)
+		return v.issues
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	v.checkValue(doc.Content[0], reflect.TypeOf(PlanConfig{}), "")
+	return v.issues
+}
+
+type planConfigValidator struct {
+	filename string
+	// tag is the struct tag that names the keys of the file (`yaml` or `json`)
+	tag string
+	// foldKeys matches keys case-insensitively
+	foldKeys bool
+	issues   []PlanConfigIssue
+}
+
+// fieldKey is the key of field in the file
+func (v *planConfigValidator) fieldKey(field reflect.StructField) string {
+	if tag := field.Tag.Get(v.tag); tag != "" {
+		return strings.Split(tag, ",")[0]
+	}
+	return field.Name
+}
+
+// normalizeKey returns the key used to look up a field
+func (v *planConfigValidator) normalizeKey(key string) string {
+	if v.foldKeys {
+		return strings.ToLower(key)
+	}
+	return key
+}
+
+func (v *planConfigValidator) errorf(node *yaml.Node, format string, args ...interface{}) {
+	v.add(node, tfprotov5.DiagnosticSeverityError, format, args...)
+}
+
+func (v *planConfigValidator) warnf(node *yaml.Node, format string, args ...interface{}) {
+	v.add(node, tfprotov5.DiagnosticSeverityWarning, format, args...)
+}
+
+func (v *planConfigValidator) add(node *yaml.Node, severity tfprotov5.DiagnosticSeverity, format string, args ...interface{}) {
+	issue := PlanConfigIssue{
+		Filename: v.filename,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if node != nil {
+		issue.Line = node.Line
+	}
+	v.issues = append(v.issues, issue)
+}
+
+// checkValue verifies that node can be decoded into a value of type t
+// path is the dotted path of the node used in messages (e.g. `connections.network.block_id`)
+func (v *planConfigValidator) checkValue(node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	displayPath := "`" + path + "`"
+	if path == "" {
+		displayPath = "the plan config"
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+			v.errorf(node, "%s must be an integer", displayPath)
+		}
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode {
+			v.errorf(node, "%s must be a string", displayPath)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			v.errorf(node, "%s must be a map", displayPath)
+			return
+		}
+		v.forEachKey(node, path, func(key string, keyNode, valNode *yaml.Node) {
+			v.checkValue(valNode, t.Elem(), joinPlanConfigPath(path, key))
+			if v.normalizeKey(path) == planConfigConnectionsKey {
+				v.checkConnection(key, keyNode, valNode)
+			}
+		})
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			v.errorf(node, "%s must be a map", displayPath)
+			return
+		}
+		fields := map[string]reflect.StructField{}
+		for i := 0; i < t.NumField(); i++ {
+			fields[v.normalizeKey(v.fieldKey(t.Field(i)))] = t.Field(i)
+		}
+		v.forEachKey(node, path, func(key string, keyNode, valNode *yaml.Node) {
+			field, ok := fields[v.normalizeKey(key)]
+			if !ok {
+				v.warnf(keyNode, "unknown key `%s`", joinPlanConfigPath(path, key))
+				return
+			}
+			v.checkValue(valNode, field.Type, joinPlanConfigPath(path, key))
+		})
+	}
+}
+
+// forEachKey iterates the key/value pairs of a mapping node, reporting duplicate keys
+func (v *planConfigValidator) forEachKey(node *yaml.Node, path string, fn func(key string, keyNode, valNode *yaml.Node)) {
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valNode := node.Content[i], node.Content[i+1]
+		key := keyNode.Value
+		if seen[v.normalizeKey(key)] {
+			v.errorf(keyNode, "duplicate key `%s`", joinPlanConfigPath(path, key))
+			continue
+		}
+		seen[v.normalizeKey(key)] = true
+		fn(key, keyNode, valNode)
+	}
+}
+
+// checkConnection verifies that a local connection references a block
+func (v *planConfigValidator) checkConnection(name string, keyNode, node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	t := reflect.TypeOf(workspaces.ManifestConnectionTarget{})
+	blockIdField, _ := t.FieldByName("BlockId")
+	blockNameField, _ := t.FieldByName("BlockName")
+	blockIdKey, blockNameKey := v.fieldKey(blockIdField), v.fieldKey(blockNameField)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, val := v.normalizeKey(node.Content[i].Value), node.Content[i+1]
+		if (key == v.normalizeKey(blockIdKey) || key == v.normalizeKey(blockNameKey)) && val.Tag != "!!null" && val.Value != "" && val.Value != "0" {
+			return
+		}
+	}
+	v.errorf(keyNode, "connection `%s` must reference a block with `%s` or `%s`", name, blockIdKey, blockNameKey)
+}
+
+func joinPlanConfigPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,220 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePlanConfigYaml(t *testing.T) {
+	const filename = ".nullstone/active-workspace.yml"
+	issue := func(line int, severity tfprotov5.DiagnosticSeverity, message string) PlanConfigIssue {
+		return PlanConfigIssue{Filename: filename, Line: line, Severity: severity, Message: message}
+	}
+	errorAt := func(line int, message string) PlanConfigIssue {
+		return issue(line, tfprotov5.DiagnosticSeverityError, message)
+	}
+	warningAt := func(line int, message string) PlanConfigIssue {
+		return issue(line, tfprotov5.DiagnosticSeverityWarning, message)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []PlanConfigIssue
+	}{
+		{
+			name:  "empty",
+			input: ``,
+			want:  nil,
+		},
+		{
+			name: "valid",
+			input: `org_name: nullstone
+stack_id: 100
+stack_name: demo
+block_id: 101
+block_name: fargate0
+block_ref: yellow-giraffe
+env_id: 102
+env_name: dev
+connections:
+  network:
+    block_id: 200
+  cluster:
+    stack_id: 100
+    block_name: cluster0
+    env_id: 102
+`,
+			want: nil,
+		},
+		{
+			name: "null values are allowed",
+			input: `stack_id:
+block_name: ~
+`,
+			want: nil,
+		},
+		{
+			name: "unknown keys",
+			input: `stack_id: 100
+stak_name: demo
+connections:
+  network:
+    block_id: 200
+    blok_name: network0
+`,
+			want: []PlanConfigIssue{
+				warningAt(2, "unknown key `stak_name`"),
+				warningAt(6, "unknown key `connections.network.blok_name`"),
+			},
+		},
+		{
+			name: "wrong types",
+			input: `stack_id: demo
+block_name:
+  - fargate0
+env_id: 1.5
+connections: network
+`,
+			want: []PlanConfigIssue{
+				errorAt(1, "`stack_id` must be an integer"),
+				errorAt(3, "`block_name` must be a string"),
+				errorAt(4, "`env_id` must be an integer"),
+				errorAt(5, "`connections` must be a map"),
+			},
+		},
+		{
+			name: "connection without block",
+			input: `connections:
+  network:
+    stack_id: 100
+  cluster:
+    block_id: 0
+  postgres: {}
+`,
+			want: []PlanConfigIssue{
+				errorAt(2, "connection `network` must reference a block with `block_id` or `block_name`"),
+				errorAt(4, "connection `cluster` must reference a block with `block_id` or `block_name`"),
+				errorAt(6, "connection `postgres` must reference a block with `block_id` or `block_name`"),
+			},
+		},
+		{
+			name: "duplicate keys",
+			input: `stack_id: 100
+stack_id: 101
+`,
+			want: []PlanConfigIssue{
+				errorAt(2, "duplicate key `stack_id`"),
+			},
+		},
+		{
+			name:  "not a map",
+			input: `- stack_id: 100`,
+			want: []PlanConfigIssue{
+				errorAt(1, "the plan config must be a map"),
+			},
+		},
+		{
+			name: "malformed yaml",
+			input: `stack_id: 100
+  block_id: 101
+`,
+			want: []PlanConfigIssue{
+				errorAt(0, "yaml: line 2: mapping values are not allowed in this context"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := validatePlanConfigYaml(filename, strings.NewReader(test.input))
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestValidatePlanConfigJson(t *testing.T) {
+	const filename = ".nullstone.json"
+	issue := func(line int, severity tfprotov5.DiagnosticSeverity, message string) PlanConfigIssue {
+		return PlanConfigIssue{Filename: filename, Line: line, Severity: severity, Message: message}
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []PlanConfigIssue
+	}{
+		{
+			name: "valid",
+			input: `{
+  "orgName": "nullstone",
+  "StackId": 100,
+  "blockName": "fargate0",
+  "envId": 102,
+  "connections": {
+    "network": {"blockId": 200}
+  }
+}`,
+			want: nil,
+		},
+		{
+			name: "invalid",
+			input: `{
+  "stackId": "100",
+  "stack_name": "demo",
+  "connections": {
+    "network": {"block_id": 200}
+  }
+}`,
+			want: []PlanConfigIssue{
+				issue(2, tfprotov5.DiagnosticSeverityError, "`stackId` must be an integer"),
+				issue(3, tfprotov5.DiagnosticSeverityWarning, "unknown key `stack_name`"),
+				issue(5, tfprotov5.DiagnosticSeverityWarning, "unknown key `connections.network.block_id`"),
+				issue(5, tfprotov5.DiagnosticSeverityError, "connection `network` must reference a block with `blockId` or `blockName`"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := validatePlanConfigJson(filename, strings.NewReader(test.input))
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestProvider_planConfigDiagnostics(t *testing.T) {
+	original, _ := os.Getwd()
+	require.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(original)
+
+	require.NoError(t, os.Mkdir(".nullstone", 0755))
+	require.NoError(t, os.WriteFile(nullstoneJsonFilename, []byte(`{"stackId": 100, "envNam": "dev"}`), 0644))
+	require.NoError(t, os.WriteFile(activeWorkspaceYmlFilename, []byte("stack_id: demo\n"), 0644))
+
+	p := &provider{PlanConfigErr: fmt.Errorf("error reading %s: invalid stack_id", activeWorkspaceYmlFilename)}
+	want := []*tfprotov5.Diagnostic{
+		{
+			Severity: tfprotov5.DiagnosticSeverityWarning,
+			Summary:  "Invalid plan config (.nullstone.json:1)",
+			Detail:   "unknown key `envNam`",
+		},
+		{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  "Invalid plan config (.nullstone/active-workspace.yml:1)",
+			Detail:   "`stack_id` must be an integer",
+		},
+		{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  "Unable to load plan config",
+			Detail:   "error reading .nullstone/active-workspace.yml: invalid stack_id",
+		},
+	}
+	assert.Equal(t, want, p.planConfigDiagnostics())
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"sync"
 
 	"github.com/hashicorp/go-tfe"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
@@ -11,47 +13,54 @@ import (
 	"github.com/nullstone-io/terraform-provider-ns/internal/server"
 	"github.com/nullstone-io/terraform-provider-ns/ns"
 	"gopkg.in/nullstone-io/go-api-client.v0"
+	"gopkg.in/nullstone-io/go-api-client.v0/auth"
+	"gopkg.in/nullstone-io/go-api-client.v0/find"
 )
 
 func Mock(version string, getNsConfig func() api.Config, getTfeConfig func() *tfe.Config, alterPlanConfig func(config *PlanConfig)) tfprotov5.ProviderServer {
-	return newProviderServer(version, func() (api.Config, *tfe.Config, PlanConfig) {
+	return newProviderServer(version, func() (api.Config, *tfe.Config, PlanConfig, PlanConfigProvenance, error) {
 		apiConfig := getNsConfig()
 		tfeConfig := getTfeConfig()
-		planConfig, _ := LoadPlanConfig()
+		planConfig, provenance, err := LoadLayeredPlanConfig()
 		if alterPlanConfig != nil {
 			alterPlanConfig(&planConfig)
 		}
-		return apiConfig, tfeConfig, planConfig
+		return apiConfig, tfeConfig, planConfig, provenance, err
 	})
 }
 
 func New(version string) tfprotov5.ProviderServer {
-	return newProviderServer(version, func() (api.Config, *tfe.Config, PlanConfig) {
+	return newProviderServer(version, func() (api.Config, *tfe.Config, PlanConfig, PlanConfigProvenance, error) {
 		apiConfig := api.DefaultConfig()
-		if profile, ac, _ := ns.LoadProfile(); profile != nil {
+		if profile, ac, _ := ns.LoadProfile(""); profile != nil {
 			apiConfig = ac
 		}
 		tfeConfig := ns.NewTfeConfig(apiConfig)
-		planConfig, _ := LoadPlanConfig()
-		return apiConfig, tfeConfig, planConfig
+		planConfig, provenance, err := LoadLayeredPlanConfig()
+		return apiConfig, tfeConfig, planConfig, provenance, err
 	})
 }
 
-func newProviderServer(version string, fn func() (api.Config, *tfe.Config, PlanConfig)) tfprotov5.ProviderServer {
+func newProviderServer(version string, fn func() (api.Config, *tfe.Config, PlanConfig, PlanConfigProvenance, error)) tfprotov5.ProviderServer {
 	s := server.MustNew(func() server.Provider {
-		apiConfig, tfeConfig, planConfig := fn()
+		apiConfig, tfeConfig, planConfig, provenance, planConfigErr := fn()
 		return &provider{
-			Version:    version,
-			NsConfig:   apiConfig,
-			TfeConfig:  tfeConfig,
-			PlanConfig: &planConfig,
+			Version:           version,
+			NsConfig:          apiConfig,
+			TfeConfig:         tfeConfig,
+			PlanConfig:        &planConfig,
+			PlanConfigSources: provenance,
+			PlanConfigErr:     planConfigErr,
 		}
 	})
 
 	// data sources
 	s.MustRegisterDataSource("ns_workspace", newDataWorkspace)
 	s.MustRegisterDataSource("ns_connection", newDataConnection)
+	s.MustRegisterDataSource("ns_connections", newDataConnections)
+	s.MustRegisterDataSource("ns_connection_graph", newDataConnectionGraph)
 	s.MustRegisterDataSource("ns_app_connection", newDataAppConnection)
+	s.MustRegisterDataSource("ns_capability", newDataCapability)
 	s.MustRegisterDataSource("ns_subdomain", newDataSubdomain)
 	s.MustRegisterDataSource("ns_domain", newDataDomain)
 	s.MustRegisterDataSource("ns_app_env", newDataAppEnv)
@@ -59,6 +68,7 @@ func newProviderServer(version string, fn func() (api.Config, *tfe.Config, PlanC
 	s.MustRegisterDataSource("ns_secret_keys", newDataSecretKeys)
 	s.MustRegisterDataSource("ns_env", newDataEnv)
 	s.MustRegisterDataSource("ns_agent", newDataAgent)
+	s.MustRegisterDataSource("ns_plan_config", newDataPlanConfig)
 
 	// resources
 	s.MustRegisterResource("ns_autogen_subdomain", newResourceAutogenSubdomain)
@@ -70,11 +80,35 @@ func newProviderServer(version string, fn func() (api.Config, *tfe.Config, PlanC
 var _ server.Provider = (*provider)(nil)
 
 type provider struct {
-	Version    string
-	TfeConfig  *tfe.Config
-	TfeClient  *tfe.Client
-	NsConfig   api.Config
-	PlanConfig *PlanConfig
+	Version     string
+	TfeConfig   *tfe.Config
+	TfeClient   *tfe.Client
+	NsConfig    api.Config
+	PlanConfig  *PlanConfig
+	StateSource ns.StateSource
+	// Resolver caches stacks, blocks, and envs looked up through the Nullstone API
+	Resolver *find.ResourceResolver
+	// PlanConfigSources records where each value in PlanConfig came from
+	PlanConfigSources PlanConfigProvenance
+	// PlanConfigErr is the error that occurred loading PlanConfig, it is reported during Validate
+	PlanConfigErr error
+	// MaxViaHops is the maximum number of connections a `via` connection can traverse
+	// If 0, defaultMaxViaHops is used
+	MaxViaHops int
+	// Strict causes connection outputs that cannot be read to be reported as errors instead of warnings
+	Strict bool
+
+	runConfigsOnce sync.Once
+	runConfigs     *runConfigResolver
+}
+
+// RunConfigs retrieves the run config resolver shared by every data source of this provider
+// The resolver is created on first use so that it uses the configured NsConfig
+func (p *provider) RunConfigs() *runConfigResolver {
+	p.runConfigsOnce.Do(func() {
+		p.runConfigs = &runConfigResolver{NsConfig: p.NsConfig, MaxConcurrency: defaultMaxConcurrentFetches}
+	})
+	return p.runConfigs
 }
 
 func (p *provider) Schema(ctx context.Context) *tfprotov5.Schema {
@@ -104,6 +138,110 @@ func (p *provider) Schema(ctx context.Context) *tfprotov5.Schema {
 					Description:     "Configure provider with the context of the capability's name",
 					DescriptionKind: tfprotov5.StringKindMarkdown,
 				},
+				{
+					Name:     "stack",
+					Type:     tftypes.String,
+					Optional: true,
+					Description: `Configure provider as if it were running in this stack (ID or name).
+This overrides the stack from the plan config and is useful with provider aliases to read connections from another workspace.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:     "block",
+					Type:     tftypes.String,
+					Optional: true,
+					Description: `Configure provider as if it were running in this block (ID or name).
+This overrides the block from the plan config and is useful with provider aliases to read connections from another workspace.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:     "env",
+					Type:     tftypes.String,
+					Optional: true,
+					Description: `Configure provider as if it were running in this environment (ID or name).
+This overrides the environment from the plan config and is useful with provider aliases to read connections from another workspace.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:     "address",
+					Type:     tftypes.String,
+					Optional: true,
+					Description: `The address of the Nullstone API.
+This takes precedence over the ` + "`NULLSTONE_ADDR`" + ` environment variable and the Nullstone profile.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:      "api_key",
+					Type:      tftypes.String,
+					Optional:  true,
+					Sensitive: true,
+					Description: `The API key used to authenticate with Nullstone.
+This takes precedence over the ` + "`NULLSTONE_API_KEY`" + ` environment variable and the Nullstone profile.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:     "profile",
+					Type:     tftypes.String,
+					Optional: true,
+					Description: `The Nullstone CLI profile used to load the API address and API key.
+This takes precedence over the ` + "`NULLSTONE_PROFILE`" + ` environment variable.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:            "tfe_address",
+					Type:            tftypes.String,
+					Optional:        true,
+					Description:     "The address of Nullstone's terraform state backend. Defaults to `address`.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:      "tfe_token",
+					Type:      tftypes.String,
+					Optional:  true,
+					Sensitive: true,
+					Description: `The token used to authenticate with Nullstone's terraform state backend.
+By default, the provider authenticates with the same credentials as the Nullstone API.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:     "state_dir",
+					Type:     tftypes.String,
+					Optional: true,
+					Description: `Read connection outputs from local state files in this directory instead of Nullstone.
+This can also be set with the ` + "`NULLSTONE_STATE_DIR`" + ` environment variable.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:            "max_state_size_mb",
+					Type:            tftypes.Number,
+					Optional:        true,
+					Description:     "The maximum size (in MB) of a connected workspace's state file that will be read for outputs, whether downloaded or in `state_dir`. Defaults to 256.",
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:            "max_via_hops",
+					Type:            tftypes.Number,
+					Optional:        true,
+					Description:     fmt.Sprintf("The maximum number of connections that a `via` connection can traverse. Defaults to %d.", defaultMaxViaHops),
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:     "strict",
+					Type:     tftypes.Bool,
+					Optional: true,
+					Description: `Cause an error instead of a warning if connection outputs cannot be read (e.g. the connected workspace has not been applied).
+Each data source can override this with its own ` + "`strict`" + `.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
+				{
+					Name:     "cache_outputs",
+					Type:     tftypes.Bool,
+					Optional: true,
+					Description: `Cache connection outputs on disk in ` + "`.nullstone/cache`" + `.
+Entries are keyed by workspace, state serial, and state version id (not lineage);
+a cached state file is only used when the connected workspace's current state version has the same serial and id.`,
+					DescriptionKind: tfprotov5.StringKindMarkdown,
+				},
 			},
 		},
 	}
@@ -119,16 +257,27 @@ func (p *provider) Validate(ctx context.Context, config map[string]tftypes.Value
 			})
 		}
 	}
-	if p.NsConfig.AccessTokenSource == nil {
+	diags = append(diags, p.planConfigDiagnostics()...)
+	nsConfig, tfeConfig, err := p.resolveConnectionConfig(config)
+	if err != nil {
 		diags = append(diags, &tfprotov5.Diagnostic{
 			Severity: tfprotov5.DiagnosticSeverityError,
-			Summary:  fmt.Sprintf("Nullstone API Key is required (Set %q environment variable)", api.ApiKeyEnvVar),
+			Summary:  "Unable to load Nullstone profile",
+			Detail:   err.Error(),
 		})
+		return diags, nil
 	}
-	if p.TfeConfig.Token == "" {
+	if nsConfig.AccessTokenSource == nil {
 		diags = append(diags, &tfprotov5.Diagnostic{
 			Severity: tfprotov5.DiagnosticSeverityError,
-			Summary:  fmt.Sprintf("TFE Token is required (Set %q environment variable)", api.ApiKeyEnvVar),
+			Summary:  fmt.Sprintf("Nullstone API Key is required (Set `api_key` or %q environment variable)", api.ApiKeyEnvVar),
+		})
+	}
+	// The TFE client authenticates with the Nullstone access token source if available, otherwise TFE_TOKEN
+	if tfeConfig.Token == "" && nsConfig.AccessTokenSource == nil && stateDirFromConfig(config) == "" {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  fmt.Sprintf("TFE Token is required (Set `tfe_token`, %q, or %q environment variable)", api.ApiKeyEnvVar, ns.TfeTokenEnvVar),
 		})
 	}
 	if !config["capability_id"].IsNull() {
@@ -150,22 +299,157 @@ func (p *provider) Validate(ctx context.Context, config map[string]tftypes.Value
 
 func (p *provider) Configure(ctx context.Context, config map[string]tftypes.Value) (diags []*tfprotov5.Diagnostic, err error) {
 	log.Printf("[DEBUG] Configuring Nullstone provider %s", p.Version)
-	if !config["organization"].IsNull() {
-		// This is already checked in Validate, just cast it
-		config["organization"].As(&p.PlanConfig.OrgName)
+	p.NsConfig, p.TfeConfig, err = p.resolveConnectionConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if p.PlanConfigSources == nil {
+		p.PlanConfigSources = PlanConfigProvenance{}
 	}
+	// capability_name is only configured through the provider block
+	p.PlanConfig.CapabilityName = ""
+	delete(p.PlanConfigSources, "capability_name")
+	p.PlanConfig.Override(PlanConfig{
+		OrgName:        extractStringFromConfig(config, "organization"),
+		CapabilityName: extractStringFromConfig(config, "capability_name"),
+	}, p.PlanConfigSources, PlanConfigSourceProvider)
+	p.PlanConfig.OverrideWorkspace(
+		extractStringFromConfig(config, "stack"),
+		extractStringFromConfig(config, "block"),
+		extractStringFromConfig(config, "env"),
+		p.PlanConfigSources,
+	)
 
 	p.NsConfig.OrgName = p.PlanConfig.OrgName
+	if maxViaHops := extractInt64FromConfig(config, "max_via_hops"); maxViaHops > 0 {
+		p.MaxViaHops = int(maxViaHops)
+	}
+	p.Strict = extractBoolFromConfig(config, "strict")
 	log.Printf("[DEBUG] Configured Nullstone API client (Address=%s)\n", p.NsConfig.BaseAddress)
-
-	p.PlanConfig.CapabilityName = extractStringFromConfig(config, "capability_name")
 	log.Printf("[DEBUG] capability_name set to %s\n", p.PlanConfig.CapabilityName)
 
+	var maxStateSize int64
+	if maxStateSizeMb := extractInt64FromConfig(config, "max_state_size_mb"); maxStateSizeMb > 0 {
+		maxStateSize = maxStateSizeMb * 1024 * 1024
+	}
+
+	diags = p.resolvePlanConfigIds(ctx)
+	if stateDir := stateDirFromConfig(config); stateDir != "" {
+		p.StateSource = ns.FsStateSource{Dir: stateDir, MaxSize: maxStateSize}
+		log.Printf("[DEBUG] Configured local state source (Dir=%s)\n", stateDir)
+		// Local state is commonly used offline, so the Nullstone API is not required to resolve names
+		return diagsAsWarnings(diags), nil
+	}
+	if diagsHaveError(diags) {
+		return diags, nil
+	}
+	log.Printf("[DEBUG] Resolved plan config workspace (%s)\n", p.PlanConfig.WorkspaceTarget().Id())
+
+	// An explicit tfe_token takes precedence over the Nullstone access token source
+	if extractStringFromConfig(config, "tfe_token") == "" {
+		if err := ns.ConfigureTfeAuth(ctx, p.TfeConfig, p.NsConfig); err != nil {
+			return nil, err
+		}
+	}
 	p.TfeClient, err = tfe.NewClient(p.TfeConfig)
 	if err != nil {
 		return nil, err
 	}
+	tfeStateSource := ns.TfeStateSource{Client: p.TfeClient, Config: p.TfeConfig, OrgName: p.PlanConfig.OrgName, MaxSize: maxStateSize}
+	if extractBoolFromConfig(config, "cache_outputs") {
+		tfeStateSource.Cache = &ns.StateCache{Dir: ns.DefaultStateCacheDir}
+		log.Printf("[DEBUG] Configured state cache (Dir=%s)\n", ns.DefaultStateCacheDir)
+	}
+	p.StateSource = tfeStateSource
 	log.Printf("[DEBUG] Configured TFE client (Address=%s, BasePath=%s)\n", p.TfeConfig.Address, p.TfeConfig.BasePath)
 
-	return nil, nil
+	return diags, nil
+}
+
+// planConfigDiagnostics reports problems with the plan config files
+func (p *provider) planConfigDiagnostics() []*tfprotov5.Diagnostic {
+	diags := make([]*tfprotov5.Diagnostic, 0)
+	for _, filename := range []string{nullstoneJsonFilename, activeWorkspaceYmlFilename} {
+		issues, err := ValidatePlanConfigFile(filename)
+		if err != nil {
+			diags = append(diags, &tfprotov5.Diagnostic{
+				Severity: tfprotov5.DiagnosticSeverityError,
+				Summary:  fmt.Sprintf("Unable to read plan config (%s)", filename),
+				Detail:   err.Error(),
+			})
+		}
+		for _, issue := range issues {
+			diags = append(diags, issue.Diagnostic())
+		}
+	}
+	// Validation may not catch every problem that fails to decode, so the decode error is always reported
+	if p.PlanConfigErr != nil {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  "Unable to load plan config",
+			Detail:   p.PlanConfigErr.Error(),
+		})
+	}
+	return diags
+}
+
+// stateDirFromConfig retrieves the local state directory from the provider block, falling back to NULLSTONE_STATE_DIR
+func stateDirFromConfig(config map[string]tftypes.Value) string {
+	if val := extractStringFromConfig(config, "state_dir"); val != "" {
+		return val
+	}
+	return os.Getenv(ns.StateDirEnvVar)
+}
+
+// resolveConnectionConfig determines the Nullstone API and TFE configuration using the following precedence:
+//  1. Provider block (`address`, `api_key`, `tfe_address`, `tfe_token`)
+//  2. Environment variables
+//  3. Nullstone profile (`profile` in the provider block selects a profile other than NULLSTONE_PROFILE)
+//
+// This does not modify the provider so that it is safe to use from Validate
+func (p *provider) resolveConnectionConfig(config map[string]tftypes.Value) (api.Config, *tfe.Config, error) {
+	nsConfig := p.NsConfig
+	tfeConfig := *p.TfeConfig
+	if profileName := extractStringFromConfig(config, "profile"); profileName != "" {
+		_, profileConfig, err := ns.LoadProfile(profileName)
+		if err != nil {
+			return nsConfig, &tfeConfig, fmt.Errorf("error loading profile %q: %w", profileName, err)
+		}
+		nsConfig = profileConfig
+		tfeConfig = *ns.NewTfeConfig(nsConfig)
+	}
+
+	if address := extractStringFromConfig(config, "address"); address != "" {
+		nsConfig.BaseAddress = address
+		tfeConfig.Address = address
+	}
+	if apiKey := extractStringFromConfig(config, "api_key"); apiKey != "" {
+		nsConfig.AccessTokenSource = auth.RawAccessTokenSource{AccessToken: apiKey}
+	}
+	if tfeAddress := extractStringFromConfig(config, "tfe_address"); tfeAddress != "" {
+		tfeConfig.Address = tfeAddress
+	}
+	if tfeToken := extractStringFromConfig(config, "tfe_token"); tfeToken != "" {
+		tfeConfig.Token = tfeToken
+	}
+	return nsConfig, &tfeConfig, nil
+}
+
+func diagsHaveError(diags []*tfprotov5.Diagnostic) bool {
+	for _, diag := range diags {
+		if diag != nil && diag.Severity == tfprotov5.DiagnosticSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// diagsAsWarnings downgrades every error in diags to a warning
+func diagsAsWarnings(diags []*tfprotov5.Diagnostic) []*tfprotov5.Diagnostic {
+	for _, diag := range diags {
+		if diag != nil && diag.Severity == tfprotov5.DiagnosticSeverityError {
+			diag.Severity = tfprotov5.DiagnosticSeverityWarning
+		}
+	}
+	return diags
 }
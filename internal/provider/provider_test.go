@@ -3,11 +3,15 @@ package provider
 import (
 	"github.com/hashicorp/go-tfe"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/nullstone-io/terraform-provider-ns/ns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/nullstone-io/go-api-client.v0"
 	"gopkg.in/nullstone-io/go-api-client.v0/auth"
 	"net/http"
 	"net/http/httptest"
+	"testing"
 )
 
 func protoV5ProviderFactories(getNsConfig func() api.Config, getTfeConfig func() *tfe.Config, alterPlanConfig func(config *PlanConfig)) map[string]func() (tfprotov5.ProviderServer, error) {
@@ -47,3 +51,45 @@ func mockTfe(handler http.Handler) (func() *tfe.Config, func()) {
 	cfg.Address = server.URL
 	return fn, server.Close
 }
+
+func TestProvider_resolveConnectionConfig(t *testing.T) {
+	nsConfig := api.Config{
+		BaseAddress:       "https://env.nullstone.io",
+		AccessTokenSource: auth.RawAccessTokenSource{AccessToken: "env-key"},
+	}
+	tfeConfig := ns.NewTfeConfig(nsConfig)
+	tfeConfig.Token = "env-tfe-token"
+	p := &provider{NsConfig: nsConfig, TfeConfig: tfeConfig}
+
+	t.Run("falls back to env when provider block is empty", func(t *testing.T) {
+		gotNs, gotTfe, err := p.resolveConnectionConfig(map[string]tftypes.Value{})
+		require.NoError(t, err)
+		assert.Equal(t, "https://env.nullstone.io", gotNs.BaseAddress)
+		assert.Equal(t, auth.RawAccessTokenSource{AccessToken: "env-key"}, gotNs.AccessTokenSource)
+		assert.Equal(t, "https://env.nullstone.io", gotTfe.Address)
+		assert.Equal(t, "env-tfe-token", gotTfe.Token)
+	})
+
+	t.Run("provider block takes precedence", func(t *testing.T) {
+		gotNs, gotTfe, err := p.resolveConnectionConfig(map[string]tftypes.Value{
+			"address":     tftypes.NewValue(tftypes.String, "https://block.nullstone.io"),
+			"api_key":     tftypes.NewValue(tftypes.String, "block-key"),
+			"tfe_address": tftypes.NewValue(tftypes.String, "https://tfe.nullstone.io"),
+			"tfe_token":   tftypes.NewValue(tftypes.String, "block-tfe-token"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "https://block.nullstone.io", gotNs.BaseAddress)
+		assert.Equal(t, auth.RawAccessTokenSource{AccessToken: "block-key"}, gotNs.AccessTokenSource)
+		assert.Equal(t, "https://tfe.nullstone.io", gotTfe.Address)
+		assert.Equal(t, "block-tfe-token", gotTfe.Token)
+	})
+
+	t.Run("does not modify the provider", func(t *testing.T) {
+		_, _, err := p.resolveConnectionConfig(map[string]tftypes.Value{
+			"address": tftypes.NewValue(tftypes.String, "https://block.nullstone.io"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "https://env.nullstone.io", p.NsConfig.BaseAddress)
+		assert.Equal(t, "https://env.nullstone.io", p.TfeConfig.Address)
+	})
+}
@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"gopkg.in/nullstone-io/go-api-client.v0"
+	"gopkg.in/nullstone-io/go-api-client.v0/find"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+// PlanConfigSourceApi indicates a plan config ID was resolved through the Nullstone API from the matching name
+const PlanConfigSourceApi = "api"
+
+// resourceResolver returns a resolver that caches stacks, blocks, and envs for the lifetime of the provider
+func (p *provider) resourceResolver() *find.ResourceResolver {
+	if p.Resolver == nil {
+		p.Resolver = find.NewResourceResolver(&api.Client{Config: p.NsConfig}, p.PlanConfig.StackId, p.PlanConfig.EnvId)
+	}
+	return p.Resolver
+}
+
+// resolvePlanConfigIds fills in the stack, block, and env IDs of the plan config when only names are supplied
+// If both an ID and a name are supplied, the ID wins (resources can be renamed); a warning is emitted if they don't match
+// A failed lookup is an error only when the ID is missing; otherwise, it is logged and the supplied ID is used
+func (p *provider) resolvePlanConfigIds(ctx context.Context) []*tfprotov5.Diagnostic {
+	pc := p.PlanConfig
+	if pc.StackName == "" && pc.BlockName == "" && pc.EnvName == "" {
+		// Nothing to resolve or verify
+		return nil
+	}
+	if pc.StackId == 0 && pc.StackName == "" {
+		return nil
+	}
+	if p.PlanConfigSources == nil {
+		p.PlanConfigSources = PlanConfigProvenance{}
+	}
+
+	diags := make([]*tfprotov5.Diagnostic, 0)
+	failed := func(kind string, required bool, err error) {
+		if !required {
+			log.Printf("[WARN] Unable to verify %s name in plan config: %s\n", kind, err)
+			return
+		}
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  fmt.Sprintf("Unable to resolve %s_id from %s_name", kind, kind),
+			Detail:   fmt.Sprintf("%s_name = %q%s: %s", kind, p.planConfigValueName(kind), p.planConfigSourceSuffix(kind+"_name"), err),
+		})
+	}
+	mismatch := func(kind string, id int64, actualName string) {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: tfprotov5.DiagnosticSeverityWarning,
+			Summary:  fmt.Sprintf("%s_id and %s_name refer to different %ss", kind, kind, kind),
+			Detail: fmt.Sprintf("%s_id = %d%s is named %q, but %s_name = %q%s. Using %s_id.",
+				kind, id, p.planConfigSourceSuffix(kind+"_id"), actualName,
+				kind, p.planConfigValueName(kind), p.planConfigSourceSuffix(kind+"_name"), kind),
+		})
+	}
+
+	resolver := p.resourceResolver()
+	sr, err := resolver.ResolveStack(ctx, types.ConnectionTarget{StackId: pc.StackId, StackName: pc.StackName})
+	if err != nil {
+		failed("stack", pc.StackId == 0, err)
+		return diags
+	}
+	if pc.StackId == 0 {
+		pc.StackId = sr.Stack.Id
+		p.PlanConfigSources["stack_id"] = PlanConfigSourceApi
+	} else if pc.StackName != "" && pc.StackName != sr.Stack.Name {
+		mismatch("stack", pc.StackId, sr.Stack.Name)
+	}
+
+	if pc.EnvName != "" {
+		var env types.Environment
+		if pc.EnvId != 0 {
+			env, err = sr.ResolveEnvById(ctx, pc.EnvId)
+		} else {
+			env, err = sr.ResolveEnvByName(ctx, pc.EnvName)
+		}
+		if err != nil {
+			failed("env", pc.EnvId == 0, err)
+		} else if pc.EnvId == 0 {
+			pc.EnvId = env.Id
+			p.PlanConfigSources["env_id"] = PlanConfigSourceApi
+		} else if pc.EnvName != "" && pc.EnvName != env.Name {
+			mismatch("env", pc.EnvId, env.Name)
+		}
+	}
+
+	if pc.BlockName != "" {
+		var block types.Block
+		if pc.BlockId != 0 {
+			block, err = sr.ResolveBlockById(ctx, pc.BlockId)
+		} else {
+			block, err = sr.ResolveBlockByName(ctx, pc.BlockName)
+		}
+		if err != nil {
+			failed("block", pc.BlockId == 0, err)
+		} else if pc.BlockId == 0 {
+			pc.BlockId = block.Id
+			p.PlanConfigSources["block_id"] = PlanConfigSourceApi
+		} else if pc.BlockName != "" && pc.BlockName != block.Name {
+			mismatch("block", pc.BlockId, block.Name)
+		}
+	}
+
+	resolver.CurStackId, resolver.CurEnvId = pc.StackId, pc.EnvId
+	return diags
+}
+
+func (p *provider) planConfigValueName(kind string) string {
+	switch kind {
+	case "stack":
+		return p.PlanConfig.StackName
+	case "block":
+		return p.PlanConfig.BlockName
+	case "env":
+		return p.PlanConfig.EnvName
+	}
+	return ""
+}
+
+// planConfigSourceSuffix describes where a plan config value came from (e.g. ` (from env:NULLSTONE_STACK_ID)`)
+func (p *provider) planConfigSourceSuffix(key string) string {
+	if source, ok := p.PlanConfigSources[key]; ok {
+		return fmt.Sprintf(" (from %s)", source)
+	}
+	return ""
+}
@@ -0,0 +1,217 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-tfe"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/nullstone-io/terraform-provider-ns/ns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+func mockNsHandlerStackBlockEnvs(stacks []*types.Stack, blocks []types.Block, envs []*types.Environment) (http.Handler, *int) {
+	requests := 0
+	writeJson := func(w http.ResponseWriter, val interface{}) {
+		requests++
+		raw, _ := json.Marshal(val)
+		w.Write(raw)
+	}
+	router := mux.NewRouter()
+	router.
+		Methods(http.MethodGet).
+		Path("/orgs/{orgName}/stacks").
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeJson(w, stacks)
+		})
+	router.
+		Methods(http.MethodGet).
+		Path("/orgs/{orgName}/stacks/{stackId}/blocks").
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			matched := make([]types.Block, 0)
+			for _, block := range blocks {
+				if mux.Vars(r)["stackId"] == strconv.FormatInt(block.StackId, 10) {
+					matched = append(matched, block)
+				}
+			}
+			writeJson(w, matched)
+		})
+	router.
+		Methods(http.MethodGet).
+		Path("/orgs/{orgName}/stacks/{stackId}/envs").
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			matched := make([]*types.Environment, 0)
+			for _, env := range envs {
+				if mux.Vars(r)["stackId"] == strconv.FormatInt(env.StackId, 10) {
+					matched = append(matched, env)
+				}
+			}
+			writeJson(w, matched)
+		})
+	return router, &requests
+}
+
+func TestProvider_resolvePlanConfigIds(t *testing.T) {
+	stacks := []*types.Stack{
+		{IdModel: types.IdModel{Id: 100}, Name: "demo", OrgName: "org0"},
+	}
+	blocks := []types.Block{
+		{IdModel: types.IdModel{Id: 101}, Name: "fargate0", OrgName: "org0", StackId: 100},
+	}
+	envs := []*types.Environment{
+		{IdModel: types.IdModel{Id: 102}, Name: "dev", OrgName: "org0", StackId: 100},
+	}
+
+	tests := []struct {
+		name        string
+		planConfig  PlanConfig
+		provenance  PlanConfigProvenance
+		want        types.WorkspaceTarget
+		wantSources PlanConfigProvenance
+		wantDiags   []*tfprotov5.Diagnostic
+		wantCalls   int
+	}{
+		{
+			name:        "resolves names",
+			planConfig:  PlanConfig{OrgName: "org0", StackName: "demo", BlockName: "fargate0", EnvName: "dev"},
+			provenance:  PlanConfigProvenance{},
+			want:        types.WorkspaceTarget{StackId: 100, BlockId: 101, EnvId: 102},
+			wantSources: PlanConfigProvenance{"stack_id": "api", "block_id": "api", "env_id": "api"},
+			wantDiags:   []*tfprotov5.Diagnostic{},
+			wantCalls:   3,
+		},
+		{
+			name:        "leaves ids alone",
+			planConfig:  PlanConfig{OrgName: "org0", StackId: 100, BlockId: 101, EnvId: 102},
+			provenance:  PlanConfigProvenance{},
+			want:        types.WorkspaceTarget{StackId: 100, BlockId: 101, EnvId: 102},
+			wantSources: PlanConfigProvenance{},
+			wantDiags:   nil,
+			wantCalls:   0,
+		},
+		{
+			name:        "warns on mismatched name",
+			planConfig:  PlanConfig{OrgName: "org0", StackId: 100, StackName: "demo", BlockId: 101, BlockName: "api", EnvId: 102},
+			provenance:  PlanConfigProvenance{"block_id": "env:NULLSTONE_BLOCK_ID", "block_name": ".nullstone.json"},
+			want:        types.WorkspaceTarget{StackId: 100, BlockId: 101, EnvId: 102},
+			wantSources: PlanConfigProvenance{"block_id": "env:NULLSTONE_BLOCK_ID", "block_name": ".nullstone.json"},
+			wantDiags: []*tfprotov5.Diagnostic{
+				{
+					Severity: tfprotov5.DiagnosticSeverityWarning,
+					Summary:  "block_id and block_name refer to different blocks",
+					Detail:   `block_id = 101 (from env:NULLSTONE_BLOCK_ID) is named "fargate0", but block_name = "api" (from .nullstone.json). Using block_id.`,
+				},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:        "errors on unknown name",
+			planConfig:  PlanConfig{OrgName: "org0", StackName: "demo", EnvName: "prod"},
+			provenance:  PlanConfigProvenance{"env_name": "env:NULLSTONE_ENV_NAME"},
+			want:        types.WorkspaceTarget{StackId: 100},
+			wantSources: PlanConfigProvenance{"stack_id": "api", "env_name": "env:NULLSTONE_ENV_NAME"},
+			wantDiags: []*tfprotov5.Diagnostic{
+				{
+					Severity: tfprotov5.DiagnosticSeverityError,
+					Summary:  "Unable to resolve env_id from env_name",
+					Detail:   `env_name = "prod" (from env:NULLSTONE_ENV_NAME): environment demo/prod does not exist`,
+				},
+			},
+			wantCalls: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler, requests := mockNsHandlerStackBlockEnvs(stacks, blocks, envs)
+			getNsConfig, closeNsFn := mockNs(handler)
+			defer closeNsFn()
+			nsConfig := getNsConfig()
+			nsConfig.OrgName = test.planConfig.OrgName
+
+			planConfig := test.planConfig
+			p := &provider{NsConfig: nsConfig, TfeConfig: tfe.DefaultConfig(), PlanConfig: &planConfig, PlanConfigSources: test.provenance}
+			diags := p.resolvePlanConfigIds(context.Background())
+			assert.Equal(t, test.wantDiags, diags)
+			assert.Equal(t, test.want, p.PlanConfig.WorkspaceTarget())
+			assert.Equal(t, test.wantSources, p.PlanConfigSources)
+			assert.Equal(t, test.wantCalls, *requests)
+		})
+	}
+
+	t.Run("caches lookups", func(t *testing.T) {
+		handler, requests := mockNsHandlerStackBlockEnvs(stacks, blocks, envs)
+		getNsConfig, closeNsFn := mockNs(handler)
+		defer closeNsFn()
+		nsConfig := getNsConfig()
+		nsConfig.OrgName = "org0"
+
+		planConfig := PlanConfig{OrgName: "org0", StackName: "demo", BlockName: "fargate0", EnvName: "dev"}
+		p := &provider{NsConfig: nsConfig, TfeConfig: tfe.DefaultConfig(), PlanConfig: &planConfig}
+		p.resolvePlanConfigIds(context.Background())
+		p.resolvePlanConfigIds(context.Background())
+		assert.Equal(t, 3, *requests)
+	})
+}
+
+func TestProvider_Configure_StateDirResolvesNames(t *testing.T) {
+	stacks := []*types.Stack{
+		{IdModel: types.IdModel{Id: 100}, Name: "demo", OrgName: "org0"},
+	}
+	blocks := []types.Block{
+		{IdModel: types.IdModel{Id: 101}, Name: "fargate0", OrgName: "org0", StackId: 100},
+	}
+	envs := []*types.Environment{
+		{IdModel: types.IdModel{Id: 102}, Name: "dev", OrgName: "org0", StackId: 100},
+	}
+	config := map[string]tftypes.Value{
+		"state_dir": tftypes.NewValue(tftypes.String, "test-fixtures/empty-state"),
+	}
+
+	tests := []struct {
+		name         string
+		planConfig   PlanConfig
+		want         types.WorkspaceTarget
+		wantSeverity []tfprotov5.DiagnosticSeverity
+	}{
+		{
+			name:         "resolves names",
+			planConfig:   PlanConfig{OrgName: "org0", StackName: "demo", BlockName: "fargate0", EnvName: "dev"},
+			want:         types.WorkspaceTarget{StackId: 100, BlockId: 101, EnvId: 102},
+			wantSeverity: []tfprotov5.DiagnosticSeverity{},
+		},
+		{
+			name:         "warns on unknown name",
+			planConfig:   PlanConfig{OrgName: "org0", StackName: "demo", BlockName: "fargate0", EnvName: "prod"},
+			want:         types.WorkspaceTarget{StackId: 100, BlockId: 101},
+			wantSeverity: []tfprotov5.DiagnosticSeverity{tfprotov5.DiagnosticSeverityWarning},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler, _ := mockNsHandlerStackBlockEnvs(stacks, blocks, envs)
+			getNsConfig, closeNsFn := mockNs(handler)
+			defer closeNsFn()
+
+			planConfig := test.planConfig
+			p := &provider{NsConfig: getNsConfig(), TfeConfig: tfe.DefaultConfig(), PlanConfig: &planConfig}
+			diags, err := p.Configure(context.Background(), config)
+			require.NoError(t, err)
+			severities := make([]tfprotov5.DiagnosticSeverity, 0)
+			for _, diag := range diags {
+				severities = append(severities, diag.Severity)
+			}
+			assert.Equal(t, test.wantSeverity, severities)
+			assert.Equal(t, test.want, p.PlanConfig.WorkspaceTarget())
+			assert.Equal(t, ns.FsStateSource{Dir: "test-fixtures/empty-state"}, p.StateSource)
+		})
+	}
+}
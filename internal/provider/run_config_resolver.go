@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nullstone-io/terraform-provider-ns/ns"
+	"gopkg.in/nullstone-io/go-api-client.v0"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+const (
+	// defaultMaxConcurrentFetches is the maximum number of workspaces that a runConfigResolver retrieves at once
+	defaultMaxConcurrentFetches = 8
+	// runConfigFetchTimeout limits a single fetch
+	// Fetches are shared by every caller, so they are detached from the context of the caller that started them
+	runConfigFetchTimeout = 2 * time.Minute
+)
+
+// runConfigResolver retrieves nullstone workspaces and their latest run configs for connection resolution
+// It is scoped to a provider instance so that every data source shares the same fetches:
+// concurrent requests for the same workspace share a single fetch and successful results are kept for the life of the provider
+// This means that via connections with a shared prefix (e.g. `cluster/network` and `cluster/subdomain`) only retrieve `cluster` once
+// No more than MaxConcurrency fetches are in flight at once
+type runConfigResolver struct {
+	NsConfig       api.Config
+	MaxConcurrency int
+
+	once    sync.Once
+	sem     chan struct{}
+	mu      sync.Mutex
+	entries map[types.WorkspaceTarget]*runConfigEntry
+}
+
+type runConfigEntry struct {
+	// done is closed once the fetch completes
+	done      chan struct{}
+	workspace *types.Workspace
+	runConfig *types.RunConfig
+	err       error
+}
+
+func (r *runConfigResolver) init() {
+	r.once.Do(func() {
+		maxConcurrency := r.MaxConcurrency
+		if maxConcurrency <= 0 {
+			maxConcurrency = defaultMaxConcurrentFetches
+		}
+		r.sem = make(chan struct{}, maxConcurrency)
+		r.entries = map[types.WorkspaceTarget]*runConfigEntry{}
+	})
+}
+
+// Get retrieves the nullstone workspace for target and its latest run config
+// If another caller is already retrieving target, this waits for that result instead of fetching again
+// If ctx is cancelled, this stops waiting, but the fetch continues for the other callers
+// Failed fetches are not kept so that a later call can retry
+func (r *runConfigResolver) Get(ctx context.Context, target types.WorkspaceTarget) (*types.Workspace, *types.RunConfig, error) {
+	r.init()
+	r.mu.Lock()
+	entry, ok := r.entries[target]
+	if !ok {
+		entry = &runConfigEntry{done: make(chan struct{})}
+		r.entries[target] = entry
+	}
+	r.mu.Unlock()
+
+	if ok {
+		log.Printf("(runConfigResolver) Waiting for workspace run config @ %s", target.Id())
+	} else {
+		// Cancelling this caller must not fail the other callers waiting on the same fetch
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), runConfigFetchTimeout)
+		go func() {
+			defer cancel()
+			r.fetch(fetchCtx, target, entry)
+		}()
+	}
+
+	select {
+	case <-entry.done:
+		return entry.workspace, entry.runConfig, entry.err
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+// GetRunConfig retrieves the latest run config for target
+// See Get
+func (r *runConfigResolver) GetRunConfig(ctx context.Context, target types.WorkspaceTarget) (*types.RunConfig, error) {
+	_, runConfig, err := r.Get(ctx, target)
+	return runConfig, err
+}
+
+// Lookup returns the workspace and run config for target if they were already retrieved successfully
+// Unlike Get, this never retrieves target or waits for a fetch in flight
+func (r *runConfigResolver) Lookup(target types.WorkspaceTarget) (*types.Workspace, *types.RunConfig, bool) {
+	r.init()
+	r.mu.Lock()
+	entry, ok := r.entries[target]
+	r.mu.Unlock()
+	if !ok {
+		return nil, nil, false
+	}
+	select {
+	case <-entry.done:
+		return entry.workspace, entry.runConfig, entry.err == nil
+	default:
+		return nil, nil, false
+	}
+}
+
+// Prefetch retrieves every target in parallel (bounded by MaxConcurrency) and waits for them to complete
+// Errors are not returned; they are reported by a subsequent Get for the same target
+func (r *runConfigResolver) Prefetch(ctx context.Context, targets []types.WorkspaceTarget) {
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target types.WorkspaceTarget) {
+			defer wg.Done()
+			r.Get(ctx, target)
+		}(target)
+	}
+	wg.Wait()
+}
+
+func (r *runConfigResolver) fetch(ctx context.Context, target types.WorkspaceTarget, entry *runConfigEntry) {
+	defer close(entry.done)
+
+	select {
+	case r.sem <- struct{}{}:
+		defer func() { <-r.sem }()
+	case <-ctx.Done():
+		entry.err = ctx.Err()
+		r.forget(target, entry)
+		return
+	}
+
+	log.Printf("(runConfigResolver) Pulling workspace run config @ %s", target.Id())
+	entry.workspace, entry.runConfig, entry.err = ns.GetWorkspaceWithConfig(ctx, r.NsConfig, target)
+	if entry.err != nil {
+		r.forget(target, entry)
+	}
+}
+
+// forget removes a failed entry so that the next Get for target fetches again
+func (r *runConfigResolver) forget(target types.WorkspaceTarget, entry *runConfigEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.entries[target] == entry {
+		delete(r.entries, target)
+	}
+}
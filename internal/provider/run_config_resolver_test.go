@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+// countingHandler counts requests for run configs and tracks the maximum number of requests in flight
+type countingHandler struct {
+	handler  http.Handler
+	delay    time.Duration
+	requests int32
+	inFlight int32
+	maxMu    sync.Mutex
+	max      int32
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/run-configs/latest") {
+		atomic.AddInt32(&h.requests, 1)
+	}
+	cur := atomic.AddInt32(&h.inFlight, 1)
+	defer atomic.AddInt32(&h.inFlight, -1)
+	h.maxMu.Lock()
+	if cur > h.max {
+		h.max = cur
+	}
+	h.maxMu.Unlock()
+	time.Sleep(h.delay)
+	h.handler.ServeHTTP(w, r)
+}
+
+func TestRunConfigResolver(t *testing.T) {
+	allWorkspaces, runConfigs := mockConnectionGraph()
+	targets := []types.WorkspaceTarget{
+		{StackId: 100, BlockId: 101, EnvId: 102},
+		{StackId: 100, BlockId: 103, EnvId: 102},
+		{StackId: 100, BlockId: 104, EnvId: 102},
+		{StackId: 100, BlockId: 105, EnvId: 102},
+	}
+
+	t.Run("shares fetches of the same workspace", func(t *testing.T) {
+		handler := &countingHandler{handler: mockNsServerWith(allWorkspaces, runConfigs), delay: 10 * time.Millisecond}
+		getNsConfig, closeNsFn := mockNs(handler)
+		defer closeNsFn()
+		nsConfig := getNsConfig()
+		nsConfig.OrgName = "org0"
+		resolver := &runConfigResolver{NsConfig: nsConfig}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				workspace, runConfig, err := resolver.Get(context.Background(), targets[1])
+				assert.NoError(t, err)
+				assert.Equal(t, "cluster", workspace.BlockName)
+				assert.Equal(t, "nullstone/aws-fargate", runConfig.Source)
+			}()
+		}
+		wg.Wait()
+		_, _, err := resolver.Get(context.Background(), targets[1])
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&handler.requests))
+	})
+
+	t.Run("limits concurrent fetches", func(t *testing.T) {
+		handler := &countingHandler{handler: mockNsServerWith(allWorkspaces, runConfigs), delay: 20 * time.Millisecond}
+		getNsConfig, closeNsFn := mockNs(handler)
+		defer closeNsFn()
+		nsConfig := getNsConfig()
+		nsConfig.OrgName = "org0"
+		resolver := &runConfigResolver{NsConfig: nsConfig, MaxConcurrency: 2}
+
+		resolver.Prefetch(context.Background(), targets)
+		assert.Equal(t, int32(len(targets)), atomic.LoadInt32(&handler.requests))
+		assert.LessOrEqual(t, handler.max, int32(2))
+	})
+
+	t.Run("continues a shared fetch when its first caller is cancelled", func(t *testing.T) {
+		handler := &countingHandler{handler: mockNsServerWith(allWorkspaces, runConfigs), delay: 50 * time.Millisecond}
+		getNsConfig, closeNsFn := mockNs(handler)
+		defer closeNsFn()
+		nsConfig := getNsConfig()
+		nsConfig.OrgName = "org0"
+		resolver := &runConfigResolver{NsConfig: nsConfig}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		firstErr := make(chan error, 1)
+		go func() {
+			_, _, err := resolver.Get(ctx, targets[1])
+			firstErr <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+
+		workspace, _, err := resolver.Get(context.Background(), targets[1])
+		require.NoError(t, err)
+		assert.Equal(t, "cluster", workspace.BlockName)
+		assert.ErrorIs(t, <-firstErr, context.Canceled)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&handler.requests))
+	})
+
+	t.Run("retries failed fetches", func(t *testing.T) {
+		handler := &countingHandler{handler: mockNsServerWith(allWorkspaces, runConfigs)}
+		getNsConfig, closeNsFn := mockNs(handler)
+		defer closeNsFn()
+		nsConfig := getNsConfig()
+		nsConfig.OrgName = "org0"
+		resolver := &runConfigResolver{NsConfig: nsConfig}
+
+		missing := types.WorkspaceTarget{StackId: 100, BlockId: 999, EnvId: 102}
+		_, _, err := resolver.Get(context.Background(), missing)
+		assert.Error(t, err)
+		_, _, err = resolver.Get(context.Background(), missing)
+		assert.Error(t, err)
+		resolver.mu.Lock()
+		assert.Empty(t, resolver.entries)
+		resolver.mu.Unlock()
+	})
+}
@@ -0,0 +1,172 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/nullstone-io/terraform-provider-ns/ns"
+	"gopkg.in/nullstone-io/go-api-client.v0"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+// workspaceOutputs holds the nullstone workspace and root-level outputs for a connection target
+type workspaceOutputs struct {
+	Workspace *types.Workspace
+	// RunConfig is the run config of Workspace, it is nil if it is unavailable (see getWorkspace)
+	RunConfig *types.RunConfig
+	// StateFile is nil if the state file could not be read
+	StateFile *ns.StateFile
+	Value     tftypes.Value
+}
+
+// connectionMetadata describes the connected workspace of a connection
+// Each field is empty if unknown
+type connectionMetadata struct {
+	StackName     string
+	BlockName     string
+	EnvName       string
+	Module        string
+	ModuleVersion string
+	// StateUpdatedAt is formatted as RFC 3339
+	StateUpdatedAt string
+}
+
+// newConnectionMetadata collects metadata from the workspace, run config, and state file already retrieved to read outputs
+func newConnectionMetadata(outputs workspaceOutputs) connectionMetadata {
+	var metadata connectionMetadata
+	if outputs.Workspace != nil {
+		metadata.StackName, metadata.BlockName, metadata.EnvName = outputs.Workspace.StackName, outputs.Workspace.BlockName, outputs.Workspace.EnvName
+	}
+	if outputs.RunConfig != nil {
+		metadata.Module, metadata.ModuleVersion = outputs.RunConfig.Source, outputs.RunConfig.SourceVersion
+	}
+	if outputs.StateFile != nil && !outputs.StateFile.UpdatedAt.IsZero() {
+		metadata.StateUpdatedAt = outputs.StateFile.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return metadata
+}
+
+// readOutputsOptions configures how readWorkspaceOutputs handles outputs that cannot be read
+type readOutputsOptions struct {
+	// Default is used as the outputs if they cannot be read; if nil, an empty map is used
+	Default *tftypes.Value
+	// Strict reports outputs that cannot be read as errors instead of warnings
+	Strict bool
+}
+
+// readWorkspaceOutputs retrieves the nullstone workspace for target and the root-level outputs from its state file
+// If the workspace cannot be found, this returns an error diagnostic and a nil workspace
+// Problems reading outputs are reported as warnings (errors if opts.Strict); in that case, outputs is opts.Default
+// A workspace that has never been applied is reported separately from failures to download or read the state file
+func (p *provider) readWorkspaceOutputs(ctx context.Context, target types.WorkspaceTarget, opts readOutputsOptions) (workspaceOutputs, []*tfprotov5.Diagnostic) {
+	result := workspaceOutputs{
+		Value: tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, map[string]tftypes.Value{}),
+	}
+	severity, fallback := tfprotov5.DiagnosticSeverityWarning, " 'outputs' will be empty"
+	if opts.Default != nil {
+		result.Value = *opts.Default
+		fallback = " 'outputs' will be 'default_outputs'"
+	}
+	if opts.Strict {
+		severity, fallback = tfprotov5.DiagnosticSeverityError, ""
+	}
+	diags := make([]*tfprotov5.Diagnostic, 0)
+
+	workspace, runConfig, err := p.getWorkspace(ctx, target)
+	if err != nil {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  fmt.Sprintf(`Unable to find nullstone workspace %s`, target.Id()),
+			Detail:   err.Error(),
+		})
+		return result, diags
+	}
+	result.Workspace, result.RunConfig = workspace, runConfig
+
+	stateFile, err := p.StateSource.GetStateFile(ctx, *workspace)
+	var noStateFile *ns.ErrNoStateFile
+	var unsupported *ns.ErrUnsupportedStateVersion
+	if errors.As(err, &noStateFile) {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: severity,
+			Summary:  fmt.Sprintf(`Workspace %q has not been applied yet.%s`, target.Id(), fallback),
+			Detail:   err.Error(),
+		})
+	} else if errors.As(err, &unsupported) {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: severity,
+			Summary:  fmt.Sprintf(`Unsupported state file version for %q.%s`, target.Id(), fallback),
+			Detail:   err.Error(),
+		})
+	} else if err != nil {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: severity,
+			Summary:  fmt.Sprintf(`Unable to download workspace outputs for %q.%s`, target.Id(), fallback),
+			Detail:   err.Error(),
+		})
+	} else if ov, err := stateFile.Outputs.ToProtov5(); err != nil {
+		diags = append(diags, &tfprotov5.Diagnostic{
+			Severity: severity,
+			Summary:  fmt.Sprintf(`Unable to read workspace outputs for %q.%s`, target.Id(), fallback),
+			Detail:   err.Error(),
+		})
+	} else {
+		result.StateFile = stateFile
+		result.Value = ov
+	}
+	return result, diags
+}
+
+// strictAttribute is the `strict` attribute shared by data sources that read connection outputs
+func strictAttribute() *tfprotov5.SchemaAttribute {
+	return &tfprotov5.SchemaAttribute{
+		Name:     "strict",
+		Type:     tftypes.Bool,
+		Optional: true,
+		Description: `Cause an error instead of a warning if connection outputs cannot be read (e.g. the connected workspace has not been applied).
+Defaults to the provider's ` + "`strict`" + `.`,
+		DescriptionKind: tfprotov5.StringKindMarkdown,
+	}
+}
+
+// strictFromConfig returns the data source's `strict` setting, falling back to the provider's `strict` setting if not set
+func (p *provider) strictFromConfig(config map[string]tftypes.Value) bool {
+	if val, ok := config["strict"]; ok && !val.IsNull() {
+		return extractBoolFromConfig(config, "strict")
+	}
+	return p.Strict
+}
+
+// getWorkspace retrieves the full nullstone workspace for the workspace target and its run config
+// If the provider's run config resolver already retrieved the workspace (e.g. to walk via connections), that result is used instead
+// Otherwise, the run config is taken from the workspace's last successful run and is nil if that is unavailable
+// The run config is only used for metadata (e.g. module version), so it is never retrieved separately
+// When reading state from the local filesystem, Nullstone may not be reachable
+// In that case, we fall back to a workspace containing only the target's ids
+func (p *provider) getWorkspace(ctx context.Context, target types.WorkspaceTarget) (*types.Workspace, *types.RunConfig, error) {
+	if workspace, runConfig, ok := p.RunConfigs().Lookup(target); ok {
+		return workspace, runConfig, nil
+	}
+	nsClient := api.Client{Config: p.NsConfig}
+	workspace, err := nsClient.Workspaces().Get(ctx, target.StackId, target.BlockId, target.EnvId)
+	if err == nil && workspace != nil {
+		var runConfig *types.RunConfig
+		if workspace.LastSuccessfulRun != nil {
+			runConfig = workspace.LastSuccessfulRun.Config
+		}
+		return workspace, runConfig, nil
+	}
+	if _, ok := p.StateSource.(ns.FsStateSource); ok {
+		log.Printf("(getWorkspace) Unable to find workspace %s in nullstone, falling back to local state lookup by id: %v", target.Id(), err)
+		return &types.Workspace{StackId: target.StackId, BlockId: target.BlockId, EnvId: target.EnvId}, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, nil, fmt.Errorf("workspace %s does not exist", target.Id())
+}
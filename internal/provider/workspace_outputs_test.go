@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/nullstone-io/terraform-provider-ns/ns"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+type stubStateSource struct {
+	StateFile *ns.StateFile
+	Err       error
+}
+
+func (s stubStateSource) GetStateFile(ctx context.Context, workspace types.Workspace) (*ns.StateFile, error) {
+	return s.StateFile, s.Err
+}
+
+func TestProvider_readWorkspaceOutputs(t *testing.T) {
+	workspace, runConfigs := mockConnectionsWorkspace()
+	target := types.WorkspaceTarget{StackId: workspace.StackId, BlockId: workspace.BlockId, EnvId: workspace.EnvId}
+	emptyOutputs := tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, map[string]tftypes.Value{})
+	defaultOutputs := tftypes.NewValue(tftypes.Object{AttributeTypes: map[string]tftypes.Type{"endpoint": tftypes.String}}, map[string]tftypes.Value{
+		"endpoint": tftypes.NewValue(tftypes.String, ""),
+	})
+	noStateFile := &ns.ErrNoStateFile{WorkspaceId: target.Id()}
+	transportErr := fmt.Errorf("error downloading state file: %w", errors.New("connection reset by peer"))
+
+	tests := []struct {
+		name         string
+		err          error
+		opts         readOutputsOptions
+		wantValue    tftypes.Value
+		wantSeverity tfprotov5.DiagnosticSeverity
+		wantSummary  string
+	}{
+		{
+			name:         "never applied",
+			err:          noStateFile,
+			wantValue:    emptyOutputs,
+			wantSeverity: tfprotov5.DiagnosticSeverityWarning,
+			wantSummary:  `Workspace "100/101/102" has not been applied yet. 'outputs' will be empty`,
+		},
+		{
+			name:         "transport failure",
+			err:          transportErr,
+			wantValue:    emptyOutputs,
+			wantSeverity: tfprotov5.DiagnosticSeverityWarning,
+			wantSummary:  `Unable to download workspace outputs for "100/101/102". 'outputs' will be empty`,
+		},
+		{
+			name:         "transport failure with default outputs",
+			err:          transportErr,
+			opts:         readOutputsOptions{Default: &defaultOutputs},
+			wantValue:    defaultOutputs,
+			wantSeverity: tfprotov5.DiagnosticSeverityWarning,
+			wantSummary:  `Unable to download workspace outputs for "100/101/102". 'outputs' will be 'default_outputs'`,
+		},
+		{
+			name:         "strict never applied",
+			err:          noStateFile,
+			opts:         readOutputsOptions{Strict: true},
+			wantValue:    emptyOutputs,
+			wantSeverity: tfprotov5.DiagnosticSeverityError,
+			wantSummary:  `Workspace "100/101/102" has not been applied yet.`,
+		},
+		{
+			name:         "strict transport failure",
+			err:          transportErr,
+			opts:         readOutputsOptions{Strict: true},
+			wantValue:    emptyOutputs,
+			wantSeverity: tfprotov5.DiagnosticSeverityError,
+			wantSummary:  `Unable to download workspace outputs for "100/101/102".`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			getNsConfig, closeNsFn := mockNs(mockNsServerWith([]types.Workspace{workspace}, runConfigs))
+			defer closeNsFn()
+			nsConfig := getNsConfig()
+			nsConfig.OrgName = "org0"
+			p := &provider{NsConfig: nsConfig, StateSource: stubStateSource{Err: test.err}}
+
+			got, diags := p.readWorkspaceOutputs(context.Background(), target, test.opts)
+			assert.Equal(t, test.wantValue, got.Value)
+			assert.Nil(t, got.StateFile)
+			if assert.Len(t, diags, 1) {
+				assert.Equal(t, test.wantSeverity, diags[0].Severity)
+				assert.Equal(t, test.wantSummary, diags[0].Summary)
+				assert.Equal(t, test.err.Error(), diags[0].Detail)
+			}
+		})
+	}
+}
+
+func TestProvider_readWorkspaceOutputs_metadata(t *testing.T) {
+	stateFile := &ns.StateFile{Version: 4, Outputs: ns.Outputs{}, UpdatedAt: time.Date(2024, 3, 1, 12, 30, 0, 0, time.FixedZone("EST", -5*60*60))}
+	want := connectionMetadata{
+		StackName:      "stack0",
+		BlockName:      "sidecar",
+		EnvName:        "env0",
+		Module:         "nullstone/aws-fargate-sidecar",
+		ModuleVersion:  "0.3.2",
+		StateUpdatedAt: "2024-03-01T17:30:00Z",
+	}
+
+	tests := []struct {
+		name string
+		// resolved causes the workspace to be retrieved through the run config resolver before reading outputs
+		resolved          bool
+		wantRunConfigGets int
+	}{
+		{
+			name:              "from last successful run",
+			wantRunConfigGets: 0,
+		},
+		{
+			name:              "already resolved",
+			resolved:          true,
+			wantRunConfigGets: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			workspace, runConfigs := mockConnectionsWorkspace()
+			runConfig := runConfigs[workspace.Uid.String()]
+			runConfig.Source, runConfig.SourceVersion = "nullstone/aws-fargate-sidecar", "0.3.2"
+			runConfigs[workspace.Uid.String()] = runConfig
+			workspace.LastSuccessfulRun = &types.Run{Config: &runConfig}
+			target := types.WorkspaceTarget{StackId: workspace.StackId, BlockId: workspace.BlockId, EnvId: workspace.EnvId}
+
+			runConfigGets := 0
+			handler := mockNsServerWith([]types.Workspace{workspace}, runConfigs)
+			getNsConfig, closeNsFn := mockNs(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/run-configs/latest") {
+					runConfigGets++
+				}
+				handler.ServeHTTP(w, r)
+			}))
+			defer closeNsFn()
+			nsConfig := getNsConfig()
+			nsConfig.OrgName = "org0"
+			p := &provider{NsConfig: nsConfig, StateSource: stubStateSource{StateFile: stateFile}}
+			if test.resolved {
+				_, _, err := p.RunConfigs().Get(context.Background(), target)
+				assert.NoError(t, err)
+			}
+
+			got, diags := p.readWorkspaceOutputs(context.Background(), target, readOutputsOptions{})
+			assert.Empty(t, diags)
+			assert.Equal(t, want, newConnectionMetadata(got))
+			assert.Equal(t, test.wantRunConfigGets, runConfigGets)
+		})
+	}
+}
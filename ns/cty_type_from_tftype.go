@@ -0,0 +1,66 @@
+package ns
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// CtyTypeFromTftype is the inverse of TftypeFromCtyType
+// cty does not support optional object attributes, so objects with OptionalAttributes are rejected
+func CtyTypeFromTftype(in tftypes.Type) (cty.Type, error) {
+	switch {
+	case in.Is(tftypes.String):
+		return cty.String, nil
+	case in.Is(tftypes.Number):
+		return cty.Number, nil
+	case in.Is(tftypes.Bool):
+		return cty.Bool, nil
+	case in.Is(tftypes.DynamicPseudoType):
+		return cty.DynamicPseudoType, nil
+	case in.Is(tftypes.Set{}):
+		elemType, err := CtyTypeFromTftype(in.(tftypes.Set).ElementType)
+		if err != nil {
+			return cty.NilType, err
+		}
+		return cty.Set(elemType), nil
+	case in.Is(tftypes.List{}):
+		elemType, err := CtyTypeFromTftype(in.(tftypes.List).ElementType)
+		if err != nil {
+			return cty.NilType, err
+		}
+		return cty.List(elemType), nil
+	case in.Is(tftypes.Tuple{}):
+		elemTypes := make([]cty.Type, 0, len(in.(tftypes.Tuple).ElementTypes))
+		for _, typ := range in.(tftypes.Tuple).ElementTypes {
+			elemType, err := CtyTypeFromTftype(typ)
+			if err != nil {
+				return cty.NilType, err
+			}
+			elemTypes = append(elemTypes, elemType)
+		}
+		return cty.Tuple(elemTypes), nil
+	case in.Is(tftypes.Map{}):
+		elemType, err := CtyTypeFromTftype(in.(tftypes.Map).ElementType)
+		if err != nil {
+			return cty.NilType, err
+		}
+		return cty.Map(elemType), nil
+	case in.Is(tftypes.Object{}):
+		obj := in.(tftypes.Object)
+		if len(obj.OptionalAttributes) > 0 {
+			return cty.NilType, fmt.Errorf("objects with optional attributes are not supported: %s", in)
+		}
+		attrTypes := make(map[string]cty.Type)
+		for key, typ := range obj.AttributeTypes {
+			attrType, err := CtyTypeFromTftype(typ)
+			if err != nil {
+				return cty.NilType, err
+			}
+			attrTypes[key] = attrType
+		}
+		return cty.Object(attrTypes), nil
+	}
+	return cty.NilType, fmt.Errorf("unknown tftypes type %s", in)
+}
@@ -0,0 +1,194 @@
+package ns
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// SensitiveMark is the cty mark applied to values that come from sensitive outputs
+// This matches the mark that terraform uses for sensitive values
+const SensitiveMark = "sensitive"
+
+// TftypeValueFromCtyValue converts a cty.Value directly into a tftypes.Value
+// Unknown and null values are preserved at every level of nesting
+// tftypes has no concept of marks, so all marks (including SensitiveMark) are removed
+// Numbers are copied as *big.Float so that no precision is lost
+func TftypeValueFromCtyValue(in cty.Value) (tftypes.Value, error) {
+	// Each level is unmarked as we recurse; UnmarkDeep in this version of cty panics on nested marks
+	in, _ = in.Unmark()
+
+	typ, err := TftypeFromCtyType(in.Type())
+	if err != nil {
+		return tftypes.Value{}, err
+	}
+	if !in.IsKnown() {
+		return tftypes.NewValue(typ, tftypes.UnknownValue), nil
+	}
+	if in.IsNull() {
+		return tftypes.NewValue(typ, nil), nil
+	}
+
+	ty := in.Type()
+	switch {
+	case ty.Equals(cty.String):
+		return tftypes.NewValue(typ, in.AsString()), nil
+	case ty.Equals(cty.Number):
+		return tftypes.NewValue(typ, new(big.Float).Copy(in.AsBigFloat())), nil
+	case ty.Equals(cty.Bool):
+		return tftypes.NewValue(typ, in.True()), nil
+	case ty.IsListType(), ty.IsSetType(), ty.IsTupleType():
+		elems := make([]tftypes.Value, 0, in.LengthInt())
+		for it := in.ElementIterator(); it.Next(); {
+			_, ev := it.Element()
+			elem, err := TftypeValueFromCtyValue(ev)
+			if err != nil {
+				return tftypes.Value{}, err
+			}
+			elems = append(elems, elem)
+		}
+		return tftypes.NewValue(typ, elems), nil
+	case ty.IsMapType(), ty.IsObjectType():
+		attrs := make(map[string]tftypes.Value, in.LengthInt())
+		for it := in.ElementIterator(); it.Next(); {
+			key, ev := it.Element()
+			attr, err := TftypeValueFromCtyValue(ev)
+			if err != nil {
+				return tftypes.Value{}, err
+			}
+			attrs[key.AsString()] = attr
+		}
+		return tftypes.NewValue(typ, attrs), nil
+	}
+	return tftypes.Value{}, fmt.Errorf("unable to convert cty value of type %s", ty.FriendlyName())
+}
+
+// CtyValueFromTftypeValue converts a tftypes.Value directly into a cty.Value
+// Unknown and null values are preserved at every level of nesting
+func CtyValueFromTftypeValue(in tftypes.Value) (cty.Value, error) {
+	typ, err := CtyTypeFromTftype(in.Type())
+	if err != nil {
+		return cty.NilVal, err
+	}
+	if !in.IsKnown() {
+		return cty.UnknownVal(typ), nil
+	}
+	if in.IsNull() {
+		return cty.NullVal(typ), nil
+	}
+
+	switch {
+	case typ.Equals(cty.DynamicPseudoType):
+		return ctyValueFromDynamicTftypeValue(in)
+	case typ.Equals(cty.String):
+		var s string
+		if err := in.As(&s); err != nil {
+			return cty.NilVal, err
+		}
+		return cty.StringVal(s), nil
+	case typ.Equals(cty.Number):
+		bf := new(big.Float)
+		if err := in.As(&bf); err != nil {
+			return cty.NilVal, err
+		}
+		return cty.NumberVal(bf), nil
+	case typ.Equals(cty.Bool):
+		var b bool
+		if err := in.As(&b); err != nil {
+			return cty.NilVal, err
+		}
+		return cty.BoolVal(b), nil
+	case typ.IsListType(), typ.IsSetType(), typ.IsTupleType():
+		var tfElems []tftypes.Value
+		if err := in.As(&tfElems); err != nil {
+			return cty.NilVal, err
+		}
+		elems := make([]cty.Value, 0, len(tfElems))
+		for _, tfElem := range tfElems {
+			elem, err := CtyValueFromTftypeValue(tfElem)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elems = append(elems, elem)
+		}
+		switch {
+		case typ.IsTupleType():
+			return cty.TupleVal(elems), nil
+		case len(elems) == 0 && typ.IsListType():
+			return cty.ListValEmpty(typ.ElementType()), nil
+		case len(elems) == 0:
+			return cty.SetValEmpty(typ.ElementType()), nil
+		case typ.IsListType():
+			return cty.ListVal(elems), nil
+		default:
+			return cty.SetVal(elems), nil
+		}
+	case typ.IsMapType(), typ.IsObjectType():
+		tfAttrs := map[string]tftypes.Value{}
+		if err := in.As(&tfAttrs); err != nil {
+			return cty.NilVal, err
+		}
+		attrs := make(map[string]cty.Value, len(tfAttrs))
+		for key, tfAttr := range tfAttrs {
+			attr, err := CtyValueFromTftypeValue(tfAttr)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			attrs[key] = attr
+		}
+		switch {
+		case typ.IsObjectType():
+			return cty.ObjectVal(attrs), nil
+		case len(attrs) == 0:
+			return cty.MapValEmpty(typ.ElementType()), nil
+		default:
+			return cty.MapVal(attrs), nil
+		}
+	}
+	return cty.NilVal, fmt.Errorf("unable to convert tftypes value of type %s", in.Type())
+}
+
+// ctyValueFromDynamicTftypeValue converts a known tftypes.Value that was created with tftypes.DynamicPseudoType
+// The concrete type is not recorded on these values, so we infer it from the underlying go value
+// Aggregates are converted to objects and tuples since their element types cannot be recovered
+func ctyValueFromDynamicTftypeValue(in tftypes.Value) (cty.Value, error) {
+	var s string
+	if err := in.As(&s); err == nil {
+		return cty.StringVal(s), nil
+	}
+	var b bool
+	if err := in.As(&b); err == nil {
+		return cty.BoolVal(b), nil
+	}
+	bf := new(big.Float)
+	if err := in.As(&bf); err == nil {
+		return cty.NumberVal(bf), nil
+	}
+	tfAttrs := map[string]tftypes.Value{}
+	if err := in.As(&tfAttrs); err == nil {
+		attrs := make(map[string]cty.Value, len(tfAttrs))
+		for key, tfAttr := range tfAttrs {
+			attr, err := CtyValueFromTftypeValue(tfAttr)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			attrs[key] = attr
+		}
+		return cty.ObjectVal(attrs), nil
+	}
+	var tfElems []tftypes.Value
+	if err := in.As(&tfElems); err == nil {
+		elems := make([]cty.Value, 0, len(tfElems))
+		for _, tfElem := range tfElems {
+			elem, err := CtyValueFromTftypeValue(tfElem)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elems = append(elems, elem)
+		}
+		return cty.TupleVal(elems), nil
+	}
+	return cty.NilVal, fmt.Errorf("unable to determine the type of dynamic value %s", in)
+}
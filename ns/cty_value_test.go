@@ -0,0 +1,191 @@
+package ns
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const roundTripIterations = 500
+
+func TestCtyTypeFromTftype_RoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < roundTripIterations; i++ {
+		typ := randomCtyType(rnd, 3)
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			tfType, err := TftypeFromCtyType(typ)
+			require.NoError(t, err, "TftypeFromCtyType(%s)", typ.GoString())
+			got, err := CtyTypeFromTftype(tfType)
+			require.NoError(t, err, "CtyTypeFromTftype(%s)", tfType)
+			assert.True(t, typ.Equals(got), "want %s, got %s", typ.GoString(), got.GoString())
+		})
+	}
+}
+
+func TestCtyValue_RoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < roundTripIterations; i++ {
+		val := randomCtyValue(rnd, randomCtyType(rnd, 3), 3)
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			tfVal, err := TftypeValueFromCtyValue(val)
+			require.NoError(t, err, "TftypeValueFromCtyValue(%s)", val.GoString())
+			got, err := CtyValueFromTftypeValue(tfVal)
+			require.NoError(t, err, "CtyValueFromTftypeValue(%s)", tfVal)
+			assert.True(t, val.RawEquals(got), "want %s, got %s", val.GoString(), got.GoString())
+		})
+	}
+}
+
+func TestTftypeValueFromCtyValue_Marks(t *testing.T) {
+	val := cty.ObjectVal(map[string]cty.Value{
+		"password": cty.StringVal("secret").Mark(SensitiveMark),
+		"hosts":    cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}).Mark("other"),
+	}).Mark(SensitiveMark)
+
+	got, err := TftypeValueFromCtyValue(val)
+	require.NoError(t, err)
+	want := tftypes.NewValue(tftypes.Object{
+		AttributeTypes: map[string]tftypes.Type{
+			"password": tftypes.String,
+			"hosts":    tftypes.List{ElementType: tftypes.String},
+		},
+	}, map[string]tftypes.Value{
+		"password": tftypes.NewValue(tftypes.String, "secret"),
+		"hosts": tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, []tftypes.Value{
+			tftypes.NewValue(tftypes.String, "a"),
+			tftypes.NewValue(tftypes.String, "b"),
+		}),
+	})
+	assert.True(t, want.Equal(got), "want %s, got %s", want, got)
+}
+
+func TestOutputs_ToProtov5_LargeNumbers(t *testing.T) {
+	typ := cty.Number
+	outputs := Outputs{
+		"big": Output{Type: &typ, Value: []byte(`123456789012345678901234567890`)},
+	}
+	got, err := outputs.ToProtov5()
+	require.NoError(t, err)
+
+	attrs := map[string]tftypes.Value{}
+	require.NoError(t, got.As(&attrs))
+	bf := new(big.Float)
+	require.NoError(t, attrs["big"].As(&bf))
+	assert.Equal(t, "123456789012345678901234567890", bf.Text('f', -1))
+}
+
+func TestOutput_CtyValue_Sensitive(t *testing.T) {
+	typ := cty.String
+	output := Output{Type: &typ, Value: []byte(`"secret"`), Sensitive: true}
+	got, err := output.CtyValue()
+	require.NoError(t, err)
+	assert.True(t, got.HasMark(SensitiveMark), "expected sensitive mark")
+}
+
+var primitiveCtyTypes = []cty.Type{cty.String, cty.Number, cty.Bool}
+
+func randomCtyType(rnd *rand.Rand, depth int) cty.Type {
+	if depth <= 0 {
+		return primitiveCtyTypes[rnd.Intn(len(primitiveCtyTypes))]
+	}
+	switch rnd.Intn(7) {
+	case 0:
+		return cty.List(randomCtyType(rnd, depth-1))
+	case 1:
+		// Sets of unknown values cannot be compared reliably, so we restrict sets to primitives
+		return cty.Set(primitiveCtyTypes[rnd.Intn(len(primitiveCtyTypes))])
+	case 2:
+		return cty.Map(randomCtyType(rnd, depth-1))
+	case 3:
+		elemTypes := make([]cty.Type, rnd.Intn(4))
+		for i := range elemTypes {
+			elemTypes[i] = randomCtyType(rnd, depth-1)
+		}
+		return cty.Tuple(elemTypes)
+	case 4:
+		attrTypes := map[string]cty.Type{}
+		for i := rnd.Intn(4); i > 0; i-- {
+			attrTypes[fmt.Sprintf("attr%d", i)] = randomCtyType(rnd, depth-1)
+		}
+		return cty.Object(attrTypes)
+	default:
+		return primitiveCtyTypes[rnd.Intn(len(primitiveCtyTypes))]
+	}
+}
+
+func randomCtyValue(rnd *rand.Rand, typ cty.Type, depth int) cty.Value {
+	switch rnd.Intn(10) {
+	case 0:
+		return cty.NullVal(typ)
+	case 1:
+		if !typ.IsSetType() {
+			return cty.UnknownVal(typ)
+		}
+	}
+
+	switch {
+	case typ.Equals(cty.String):
+		return cty.StringVal(fmt.Sprintf("value-%d", rnd.Int()))
+	case typ.Equals(cty.Number):
+		// Include integers beyond float64 precision
+		bf, _, _ := big.ParseFloat(fmt.Sprintf("%d%d", rnd.Int63(), rnd.Int63()), 10, 512, big.ToNearestEven)
+		return cty.NumberVal(bf)
+	case typ.Equals(cty.Bool):
+		return cty.BoolVal(rnd.Intn(2) == 0)
+	case typ.IsListType(), typ.IsSetType():
+		n := rnd.Intn(4)
+		if n == 0 {
+			if typ.IsListType() {
+				return cty.ListValEmpty(typ.ElementType())
+			}
+			return cty.SetValEmpty(typ.ElementType())
+		}
+		elems := make([]cty.Value, n)
+		for i := range elems {
+			elems[i] = randomKnownCtyValue(rnd, typ.ElementType(), depth-1, typ.IsSetType())
+		}
+		if typ.IsListType() {
+			return cty.ListVal(elems)
+		}
+		return cty.SetVal(elems)
+	case typ.IsMapType():
+		n := rnd.Intn(4)
+		if n == 0 {
+			return cty.MapValEmpty(typ.ElementType())
+		}
+		elems := map[string]cty.Value{}
+		for i := 0; i < n; i++ {
+			elems[fmt.Sprintf("key%d", i)] = randomCtyValue(rnd, typ.ElementType(), depth-1)
+		}
+		return cty.MapVal(elems)
+	case typ.IsTupleType():
+		elems := make([]cty.Value, 0)
+		for _, elemType := range typ.TupleElementTypes() {
+			elems = append(elems, randomCtyValue(rnd, elemType, depth-1))
+		}
+		return cty.TupleVal(elems)
+	case typ.IsObjectType():
+		attrs := map[string]cty.Value{}
+		for name, attrType := range typ.AttributeTypes() {
+			attrs[name] = randomCtyValue(rnd, attrType, depth-1)
+		}
+		return cty.ObjectVal(attrs)
+	}
+	panic(fmt.Sprintf("unexpected type %s", typ.GoString()))
+}
+
+// randomKnownCtyValue generates a value that is never unknown when it is a set element
+func randomKnownCtyValue(rnd *rand.Rand, typ cty.Type, depth int, known bool) cty.Value {
+	for {
+		val := randomCtyValue(rnd, typ, depth)
+		if !known || (val.IsKnown() && !val.IsNull()) {
+			return val
+		}
+	}
+}
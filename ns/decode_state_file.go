@@ -0,0 +1,186 @@
+package ns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxStateFileSize is the default limit on the size of a state file that is read to extract outputs
+const DefaultMaxStateFileSize int64 = 256 * 1024 * 1024
+
+type ErrStateFileTooLarge struct {
+	MaxSize int64
+}
+
+func (e *ErrStateFileTooLarge) Error() string {
+	return fmt.Sprintf("state file exceeds the maximum size of %d bytes", e.MaxSize)
+}
+
+// DecodeStateFile reads a state file from r without loading the entire document into memory
+// Only the header fields and outputs are retained; everything else (e.g. resources) is skipped token by token
+// If maxSize > 0, reading more than maxSize bytes results in ErrStateFileTooLarge
+func DecodeStateFile(r io.Reader, maxSize int64) (*StateFile, error) {
+	if maxSize > 0 {
+		r = &limitedReader{R: r, N: maxSize}
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var v3 stateFileV3
+	var v4Outputs Outputs
+	header := &StateFile{}
+	err := decodeObject(dec, func(key string) error {
+		switch key {
+		case "version":
+			return dec.Decode(&header.Version)
+		case "terraform_version":
+			return dec.Decode(&header.TerraformVersion)
+		case "serial":
+			return dec.Decode(&header.Serial)
+		case "lineage":
+			return dec.Decode(&header.Lineage)
+		case "outputs":
+			return dec.Decode(&v4Outputs)
+		case "modules":
+			return decodeArray(dec, func() error {
+				module, err := decodeModuleStateV3(dec)
+				if err != nil {
+					return err
+				}
+				v3.Modules = append(v3.Modules, module)
+				return nil
+			})
+		default:
+			return skipValue(dec)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch header.Version {
+	case 4:
+		header.Outputs = v4Outputs
+		if header.Outputs == nil {
+			header.Outputs = Outputs{}
+		}
+		return header, nil
+	case 3:
+		v3.Version, v3.TerraformVersion, v3.Serial, v3.Lineage = header.Version, header.TerraformVersion, header.Serial, header.Lineage
+		return v3.Normalize()
+	default:
+		return nil, &ErrUnsupportedStateVersion{Version: header.Version}
+	}
+}
+
+// decodeModuleStateV3 decodes a single entry of `modules` in a v3 state file, skipping resources
+func decodeModuleStateV3(dec *json.Decoder) (moduleStateV3, error) {
+	var module moduleStateV3
+	err := decodeObject(dec, func(key string) error {
+		switch key {
+		case "path":
+			return dec.Decode(&module.Path)
+		case "outputs":
+			return dec.Decode(&module.Outputs)
+		default:
+			return skipValue(dec)
+		}
+	})
+	return module, err
+}
+
+// decodeObject reads a JSON object from dec, calling fn for each key
+// fn is responsible for consuming the value for each key
+func decodeObject(dec *json.Decoder, fn func(key string) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("expected object key, got %v", tok)
+		}
+		if err := fn(key); err != nil {
+			return fmt.Errorf("error reading %q: %w", key, err)
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// decodeArray reads a JSON array from dec, calling fn for each element
+// fn is responsible for consuming each element
+func decodeArray(dec *json.Decoder, fn func() error) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	for dec.More() {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// skipValue consumes the next JSON value from dec without retaining it
+// Unlike dec.Decode, this does not buffer the entire value in memory
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := tok.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if got, ok := tok.(json.Delim); !ok || got != want {
+		return fmt.Errorf("expected %q, got %v", want, tok)
+	}
+	return nil
+}
+
+// limitedReader is similar to io.LimitedReader, but reports ErrStateFileTooLarge instead of io.EOF
+type limitedReader struct {
+	R io.Reader
+	N int64
+	// read tracks the number of bytes read so far
+	read int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.read >= l.N {
+		// Verify there is more data before failing so that a file of exactly N bytes is accepted
+		var probe [1]byte
+		if n, _ := l.R.Read(probe[:]); n > 0 {
+			return 0, &ErrStateFileTooLarge{MaxSize: l.N}
+		}
+		return 0, io.EOF
+	}
+	if remaining := l.N - l.read; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := l.R.Read(p)
+	l.read += int64(n)
+	return n, err
+}
@@ -0,0 +1,125 @@
+package ns
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeStateFile(t *testing.T) {
+	raw := largeStateFile(100)
+
+	t.Run("extracts header and outputs while skipping resources", func(t *testing.T) {
+		got, err := DecodeStateFile(bytes.NewReader(raw), 0)
+		require.NoError(t, err)
+		assert.Equal(t, 4, got.Version)
+		assert.Equal(t, "1.5.7", got.TerraformVersion)
+		assert.Equal(t, int64(42), got.Serial)
+		assert.Equal(t, "b3f0e9c2-5d1a-4f6e-8a7b-9c0d1e2f3a4b", got.Lineage)
+		if assert.Contains(t, got.Outputs, "vpc_id") {
+			assert.Equal(t, json.RawMessage(`"vpc-0123456789"`), got.Outputs["vpc_id"].Value)
+		}
+	})
+
+	t.Run("accepts a state file of exactly the maximum size", func(t *testing.T) {
+		_, err := DecodeStateFile(bytes.NewReader(raw), int64(len(raw)))
+		assert.NoError(t, err)
+	})
+
+	t.Run("rejects a state file larger than the maximum size", func(t *testing.T) {
+		_, err := DecodeStateFile(bytes.NewReader(raw), int64(len(raw)-1))
+		var tooLarge *ErrStateFileTooLarge
+		assert.True(t, errors.As(err, &tooLarge), "expected ErrStateFileTooLarge, got %v", err)
+	})
+
+	t.Run("matches json.Unmarshal for existing fixtures", func(t *testing.T) {
+		for _, name := range []string{"01.json", "02.json", "03.json", "04.json", "05.json"} {
+			raw, err := os.ReadFile(filepath.Join("test-fixtures", "state-files", name))
+			require.NoError(t, err, "read input file")
+			got, err := DecodeStateFile(bytes.NewReader(raw), 0)
+			require.NoError(t, err, name)
+			assert.Len(t, got.Outputs, countFixtureOutputs(t, raw), name)
+		}
+	})
+}
+
+// BenchmarkDecodeStateFile_Buffered reproduces the previous behavior:
+// the entire state file was read into memory and then parsed with json.Unmarshal
+func BenchmarkDecodeStateFile_Buffered(b *testing.B) {
+	raw := largeStateFile(20000)
+	b.SetBytes(int64(len(raw)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, err := io.ReadAll(bytes.NewReader(raw))
+		if err != nil {
+			b.Fatal(err)
+		}
+		var full struct {
+			StateFile
+			Resources []json.RawMessage `json:"resources"`
+		}
+		if err := json.Unmarshal(buf, &full); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDecodeStateFile_Streaming streams the state file and retains only the header and outputs
+func BenchmarkDecodeStateFile_Streaming(b *testing.B) {
+	raw := largeStateFile(20000)
+	b.SetBytes(int64(len(raw)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodeStateFile(bytes.NewReader(raw), 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// largeStateFile generates a v4 state file with numResources resources (~1KB each) and a handful of outputs
+func largeStateFile(numResources int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"version":4,"terraform_version":"1.5.7","serial":42,"lineage":"b3f0e9c2-5d1a-4f6e-8a7b-9c0d1e2f3a4b",`)
+	buf.WriteString(`"outputs":{"vpc_id":{"value":"vpc-0123456789","type":"string"},"subnet_ids":{"value":["subnet-1","subnet-2"],"type":["list","string"]}},`)
+	buf.WriteString(`"resources":[`)
+	for i := 0; i < numResources; i++ {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, `{"mode":"managed","type":"aws_instance","name":"node%d","provider":"provider[\"registry.terraform.io/hashicorp/aws\"]",`, i)
+		fmt.Fprintf(&buf, `"instances":[{"schema_version":1,"attributes":{"id":"i-%016d","ami":"ami-0123456789abcdef0","tags":{"Name":"node%d","Stack":"core","Env":"prod"},`, i, i)
+		buf.WriteString(`"user_data":"IyEvYmluL2Jhc2gKZWNobyAiaGVsbG8gd29ybGQiCmVjaG8gImhlbGxvIHdvcmxkIgplY2hvICJoZWxsbyB3b3JsZCIKZWNobyAiaGVsbG8gd29ybGQiCmVjaG8gImhlbGxvIHdvcmxkIgplY2hvICJoZWxsbyB3b3JsZCIKZWNobyAiaGVsbG8gd29ybGQiCmVjaG8gImhlbGxvIHdvcmxkIgplY2hvICJoZWxsbyB3b3JsZCIKZWNobyAiaGVsbG8gd29ybGQiCmVjaG8gImhlbGxvIHdvcmxkIgplY2hvICJoZWxsbyB3b3JsZCIK",`)
+		buf.WriteString(`"security_groups":["sg-0123456789abcdef0","sg-0123456789abcdef1","sg-0123456789abcdef2"],"ebs_block_device":[{"device_name":"/dev/sdb","volume_size":100,"volume_type":"gp3","encrypted":true}]},`)
+		buf.WriteString(`"sensitive_attributes":[],"private":"eyJzY2hlbWFfdmVyc2lvbiI6IjEifQ==","dependencies":["aws_security_group.this","aws_subnet.private"]}]}`)
+	}
+	buf.WriteString(`]}`)
+	return buf.Bytes()
+}
+
+func countFixtureOutputs(t *testing.T, raw []byte) int {
+	var generic struct {
+		Outputs map[string]json.RawMessage `json:"outputs"`
+		Modules []struct {
+			Path    []string                   `json:"path"`
+			Outputs map[string]json.RawMessage `json:"outputs"`
+		} `json:"modules"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &generic))
+	count := len(generic.Outputs)
+	for _, module := range generic.Modules {
+		if len(module.Path) == 1 && module.Path[0] == "root" {
+			count += len(module.Outputs)
+		}
+	}
+	return count
+}
@@ -0,0 +1,99 @@
+package ns
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/google/uuid"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+const (
+	StateDirEnvVar = "NULLSTONE_STATE_DIR"
+	StateFilename  = "terraform.tfstate"
+)
+
+var _ StateSource = FsStateSource{}
+
+// FsStateSource reads state files from a local directory instead of Nullstone
+// This is useful for local module development when Nullstone's state backend is not reachable
+// The state file for a workspace is located by the first path that exists:
+//  1. <Dir>/<workspace-uid>/terraform.tfstate
+//  2. <Dir>/<stack-name>/<env-name>/<block-name>/terraform.tfstate
+//  3. <Dir>/<stack-id>/<env-id>/<block-id>/terraform.tfstate
+type FsStateSource struct {
+	Dir string
+	// MaxSize limits the size of a state file that is read
+	// If 0, DefaultMaxStateFileSize is used
+	MaxSize int64
+}
+
+func (s FsStateSource) GetStateFile(ctx context.Context, workspace types.Workspace) (*StateFile, error) {
+	for _, candidate := range s.candidatePaths(workspace) {
+		file, err := os.Open(candidate)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading state file %s: %w", candidate, err)
+		}
+
+		log.Printf("[DEBUG] Reading state file (workspace=%s) from %s\n", workspaceTargetOf(workspace).Id(), candidate)
+		stateFile, err := s.decode(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing state file %s: %w", candidate, err)
+		}
+		return stateFile, nil
+	}
+	return nil, &ErrNoStateFile{WorkspaceId: workspaceTargetOf(workspace).Id(), Dir: s.Dir}
+}
+
+func (s FsStateSource) maxSize() int64 {
+	if s.MaxSize > 0 {
+		return s.MaxSize
+	}
+	return DefaultMaxStateFileSize
+}
+
+func (s FsStateSource) decode(file *os.File) (*StateFile, error) {
+	maxSize := s.maxSize()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxSize {
+		return nil, &ErrStateFileTooLarge{MaxSize: maxSize}
+	}
+	stateFile, err := DecodeStateFile(file, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	stateFile.UpdatedAt = info.ModTime()
+	return stateFile, nil
+}
+
+func (s FsStateSource) candidatePaths(workspace types.Workspace) []string {
+	paths := make([]string, 0)
+	if workspace.Uid != uuid.Nil {
+		paths = append(paths, filepath.Join(s.Dir, workspace.Uid.String(), StateFilename))
+	}
+	if workspace.StackName != "" && workspace.EnvName != "" && workspace.BlockName != "" {
+		paths = append(paths, filepath.Join(s.Dir, workspace.StackName, workspace.EnvName, workspace.BlockName, StateFilename))
+	}
+	stackId := strconv.FormatInt(workspace.StackId, 10)
+	envId := strconv.FormatInt(workspace.EnvId, 10)
+	blockId := strconv.FormatInt(workspace.BlockId, 10)
+	return append(paths, filepath.Join(s.Dir, stackId, envId, blockId, StateFilename))
+}
+
+func workspaceTargetOf(workspace types.Workspace) types.WorkspaceTarget {
+	return types.WorkspaceTarget{
+		StackId: workspace.StackId,
+		BlockId: workspace.BlockId,
+		EnvId:   workspace.EnvId,
+	}
+}
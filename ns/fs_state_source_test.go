@@ -0,0 +1,81 @@
+package ns
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+func TestFsStateSource_GetStateFile(t *testing.T) {
+	source := FsStateSource{Dir: filepath.Join("test-fixtures", "local-state")}
+
+	tests := []struct {
+		name       string
+		workspace  types.Workspace
+		wantSource string
+		wantErr    bool
+	}{
+		{
+			name: "keyed by workspace uid",
+			workspace: types.Workspace{
+				UidCreatedModel: types.UidCreatedModel{Uid: uuid.MustParse("2f1c9a52-7c1b-4bb2-9f34-6e1f3f0d8d11")},
+				StackId:         100,
+				BlockId:         103,
+				EnvId:           102,
+			},
+			wantSource: `"uid"`,
+		},
+		{
+			name: "keyed by stack/env/block names",
+			workspace: types.Workspace{
+				UidCreatedModel: types.UidCreatedModel{Uid: uuid.New()},
+				StackId:         100,
+				StackName:       "core",
+				BlockId:         103,
+				BlockName:       "network0",
+				EnvId:           102,
+				EnvName:         "dev",
+			},
+			wantSource: `"names"`,
+		},
+		{
+			name:       "keyed by stack/env/block ids",
+			workspace:  types.Workspace{StackId: 100, BlockId: 103, EnvId: 102},
+			wantSource: `"ids"`,
+		},
+		{
+			name:      "missing state file",
+			workspace: types.Workspace{StackId: 100, BlockId: 104, EnvId: 102},
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := source.GetStateFile(context.Background(), test.workspace)
+			if test.wantErr {
+				var noStateFile *ErrNoStateFile
+				assert.ErrorAs(t, err, &noStateFile)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, json.RawMessage(test.wantSource), got.Outputs["source"].Value)
+			assert.False(t, got.UpdatedAt.IsZero(), "UpdatedAt should be the modification time of the state file")
+		})
+	}
+}
+
+func TestFsStateSource_GetStateFile_MaxSize(t *testing.T) {
+	source := FsStateSource{Dir: filepath.Join("test-fixtures", "local-state"), MaxSize: 10}
+	_, err := source.GetStateFile(context.Background(), types.Workspace{StackId: 100, BlockId: 103, EnvId: 102})
+	var tooLarge *ErrStateFileTooLarge
+	if assert.ErrorAs(t, err, &tooLarge) {
+		assert.Equal(t, int64(10), tooLarge.MaxSize)
+	}
+}
@@ -8,16 +8,22 @@ import (
 )
 
 func GetWorkspaceConfig(ctx context.Context, config api.Config, target types.WorkspaceTarget) (*types.RunConfig, error) {
+	_, runConfig, err := GetWorkspaceWithConfig(ctx, config, target)
+	return runConfig, err
+}
+
+// GetWorkspaceWithConfig retrieves the nullstone workspace for target and its latest run config
+func GetWorkspaceWithConfig(ctx context.Context, config api.Config, target types.WorkspaceTarget) (*types.Workspace, *types.RunConfig, error) {
 	nsClient := api.Client{Config: config}
 	workspace, err := nsClient.Workspaces().Get(ctx, target.StackId, target.BlockId, target.EnvId)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	} else if workspace == nil {
-		return nil, fmt.Errorf("no nullstone workspace %s", target.Id())
+		return nil, nil, fmt.Errorf("no nullstone workspace %s", target.Id())
 	}
 	runConfig, err := nsClient.RunConfigs().GetLatest(ctx, workspace.StackId, workspace.Uid)
 	if err != nil {
-		return nil, err
+		return workspace, nil, err
 	}
-	return runConfig, nil
+	return workspace, runConfig, nil
 }
@@ -12,8 +12,13 @@ const (
 	DefaultNullstoneProfile = "default"
 )
 
-func LoadProfile() (*config.Profile, api.Config, error) {
-	profileName := os.Getenv(NullstoneProfileEnvVar)
+// LoadProfile loads the Nullstone API configuration from a Nullstone CLI profile
+// If profileName is empty, the profile is selected by NULLSTONE_PROFILE or "default"
+// Environment variables (NULLSTONE_ADDR, NULLSTONE_API_KEY) take precedence over the profile
+func LoadProfile(profileName string) (*config.Profile, api.Config, error) {
+	if profileName == "" {
+		profileName = os.Getenv(NullstoneProfileEnvVar)
+	}
 	if profileName == "" {
 		profileName = DefaultNullstoneProfile
 	}
@@ -23,10 +28,10 @@ func LoadProfile() (*config.Profile, api.Config, error) {
 	}
 
 	cfg := api.DefaultConfig()
-	if profile.Address != "" {
+	if profile.Address != "" && os.Getenv(api.AddressEnvVar) == "" {
 		cfg.BaseAddress = profile.Address
 	}
-	if profile.ApiKey != "" {
+	if profile.ApiKey != "" && os.Getenv(api.ApiKeyEnvVar) == "" {
 		apiKey := config.CleanseApiKey(profile.ApiKey)
 		cfg.AccessTokenSource = auth.RawAccessTokenSource{AccessToken: apiKey}
 	}
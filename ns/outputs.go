@@ -2,36 +2,82 @@ package ns
 
 import (
 	"encoding/json"
+	"fmt"
+	"sort"
 
 	"github.com/hashicorp/go-cty/cty"
-	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/go-cty/cty/convert"
+	ctyjson "github.com/hashicorp/go-cty/cty/json"
 	"github.com/hashicorp/terraform-plugin-go/tftypes"
 )
 
 type Outputs map[string]Output
 
 type Output struct {
-	Type  *cty.Type       `json:"type"`
-	Value json.RawMessage `json:"value"`
+	Type      *cty.Type       `json:"type"`
+	Value     json.RawMessage `json:"value"`
+	Sensitive bool            `json:"sensitive,omitempty"`
 }
 
+// CtyValue parses the raw output value using the output's type
+// If the output is sensitive, the resulting value is marked with SensitiveMark
+func (o Output) CtyValue() (cty.Value, error) {
+	if o.Type == nil {
+		return cty.NilVal, fmt.Errorf("output is missing a type")
+	}
+	val, err := ctyjson.Unmarshal(o.Value, *o.Type)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	if o.Sensitive {
+		val = val.Mark(SensitiveMark)
+	}
+	return val, nil
+}
+
+// ToProtov5 converts every output into a single object value
+// Each output is converted directly from its cty value to avoid a lossy JSON round trip (e.g. large integers)
 func (o Outputs) ToProtov5() (tftypes.Value, error) {
 	objType := tftypes.Object{AttributeTypes: map[string]tftypes.Type{}}
 	all := map[string]tftypes.Value{}
 
 	for name, output := range o {
-		destType, err := TftypeFromCtyType(*output.Type)
+		if output.Type == nil {
+			return tftypes.Value{}, fmt.Errorf("output %q is missing a type", name)
+		}
+		ctyVal, err := output.CtyValue()
 		if err != nil {
-			return tftypes.Value{}, err
+			return tftypes.Value{}, fmt.Errorf("error reading output %q: %w", name, err)
 		}
-		objType.AttributeTypes[name] = destType
-
-		rs := tfprotov5.RawState{JSON: output.Value}
-		val, err := rs.Unmarshal(destType)
+		val, err := TftypeValueFromCtyValue(ctyVal)
 		if err != nil {
-			return tftypes.Value{}, err
+			return tftypes.Value{}, fmt.Errorf("error converting output %q: %w", name, err)
 		}
+		objType.AttributeTypes[name] = val.Type()
 		all[name] = val
 	}
 	return tftypes.NewValue(objType, all), nil
 }
+
+// CheckRequired verifies that every required output exists and can be converted to its type constraint
+// Use cty.DynamicPseudoType to require an output of any type
+// This returns a sorted description of every missing or mistyped output
+func (o Outputs) CheckRequired(required map[string]cty.Type) []string {
+	problems := make([]string, 0)
+	for name, want := range required {
+		output, ok := o[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("output %q is missing", name))
+			continue
+		}
+		if output.Type == nil {
+			problems = append(problems, fmt.Sprintf("output %q is missing a type", name))
+			continue
+		}
+		if !output.Type.Equals(want) && convert.GetConversion(*output.Type, want) == nil {
+			problems = append(problems, fmt.Sprintf("output %q has type %s, expected %s", name, output.Type.FriendlyName(), want.FriendlyName()))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
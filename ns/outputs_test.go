@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -89,3 +90,37 @@ func TestOutputs_ToProtov5(t *testing.T) {
 		})
 	}
 }
+
+func TestOutputs_CheckRequired(t *testing.T) {
+	typ := func(t cty.Type) *cty.Type { return &t }
+	outputs := Outputs{
+		"cluster_arn": {Type: typ(cty.String), Value: json.RawMessage(`"arn"`)},
+		"port":        {Type: typ(cty.Number), Value: json.RawMessage(`5432`)},
+		"subnet_ids":  {Type: typ(cty.Tuple([]cty.Type{cty.String, cty.String})), Value: json.RawMessage(`["a","b"]`)},
+		"tags":        {Type: typ(cty.Object(map[string]cty.Type{"Name": cty.String})), Value: json.RawMessage(`{"Name":"a"}`)},
+		"untyped":     {Value: json.RawMessage(`"a"`)},
+	}
+
+	got := outputs.CheckRequired(map[string]cty.Type{
+		"cluster_arn":     cty.String,
+		"port":            cty.String,
+		"subnet_ids":      cty.List(cty.String),
+		"tags":            cty.Map(cty.String),
+		"untyped":         cty.DynamicPseudoType,
+		"security_groups": cty.DynamicPseudoType,
+		"db_name":         cty.Bool,
+	})
+	want := []string{
+		`output "db_name" is missing`,
+		`output "security_groups" is missing`,
+		`output "untyped" is missing a type`,
+	}
+	assert.Equal(t, want, got)
+
+	got = outputs.CheckRequired(map[string]cty.Type{"cluster_arn": cty.Number, "tags": cty.List(cty.String)})
+	want = []string{
+		`output "cluster_arn" has type string, expected number`,
+		`output "tags" has type object, expected list of string`,
+	}
+	assert.Equal(t, want, got)
+}
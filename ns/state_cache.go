@@ -0,0 +1,143 @@
+package ns
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultStateCacheDir is the default location of the on-disk cache of upstream outputs
+var DefaultStateCacheDir = filepath.Join(".nullstone", "cache")
+
+// StateCache is an on-disk cache of the outputs extracted from state files
+// Entries are stored at <Dir>/<workspace-uid>/<serial>.json and record the lineage and state version id
+// An entry is only used if the workspace's current state version has the same serial and state version id
+// The lineage is not compared because the state backend does not report it until the state file is downloaded;
+// every upload creates a new state version id, so a state file with a new lineage never matches an existing entry
+// Only the latest entry is kept for each workspace
+//
+// Entries contain sensitive outputs unencrypted, so they are only readable by the current user
+// and Dir contains a .gitignore so that entries are not committed by accident
+//
+// Entries are written to a temp file and atomically renamed into place,
+// so concurrent provider processes will never observe a partially written entry
+type StateCache struct {
+	Dir string
+}
+
+type stateCacheEntry struct {
+	StateVersionId   string  `json:"state_version_id"`
+	Version          int     `json:"version"`
+	TerraformVersion string  `json:"terraform_version"`
+	Serial           int64   `json:"serial"`
+	Lineage          string  `json:"lineage"`
+	Outputs          Outputs `json:"outputs"`
+}
+
+// Get retrieves the cached state file for the workspace at the current state version
+// This returns nil if there is no usable entry in the cache
+func (c StateCache) Get(workspaceUid string, stateVersionId string, serial int64) *StateFile {
+	raw, err := os.ReadFile(c.entryPath(workspaceUid, serial))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[DEBUG] Unable to read state cache entry (workspace=%s, serial=%d): %s\n", workspaceUid, serial, err)
+		}
+		return nil
+	}
+	var entry stateCacheEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		log.Printf("[DEBUG] Ignoring corrupt state cache entry (workspace=%s, serial=%d): %s\n", workspaceUid, serial, err)
+		return nil
+	}
+	if entry.StateVersionId != stateVersionId || entry.Serial != serial {
+		return nil
+	}
+	return &StateFile{
+		Version:          entry.Version,
+		TerraformVersion: entry.TerraformVersion,
+		Serial:           entry.Serial,
+		Lineage:          entry.Lineage,
+		Outputs:          entry.Outputs,
+	}
+}
+
+// Put stores the state file for the workspace's state version in the cache
+func (c StateCache) Put(workspaceUid string, stateVersionId string, stateFile *StateFile) error {
+	entry := stateCacheEntry{
+		StateVersionId:   stateVersionId,
+		Version:          stateFile.Version,
+		TerraformVersion: stateFile.TerraformVersion,
+		Serial:           stateFile.Serial,
+		Lineage:          stateFile.Lineage,
+		Outputs:          stateFile.Outputs,
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	dest := c.entryPath(workspaceUid, stateFile.Serial)
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return fmt.Errorf("error creating state cache directory: %w", err)
+	}
+	if err := c.ensureGitignore(); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating state cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing state cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing state cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("error writing state cache entry: %w", err)
+	}
+	c.prune(workspaceUid, filepath.Base(dest))
+	return nil
+}
+
+// ensureGitignore writes a .gitignore into Dir that ignores the entire cache
+func (c StateCache) ensureGitignore() error {
+	path := filepath.Join(c.Dir, ".gitignore")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte("# Created by terraform-provider-ns; cached outputs may contain secrets\n*\n"), 0644); err != nil {
+		return fmt.Errorf("error writing state cache .gitignore: %w", err)
+	}
+	return nil
+}
+
+// prune removes every entry for the workspace other than keep
+// Failures are logged because a stale entry is never used
+func (c StateCache) prune(workspaceUid string, keep string) {
+	dir := filepath.Join(c.Dir, workspaceUid)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("[DEBUG] Unable to prune state cache (workspace=%s): %s\n", workspaceUid, err)
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == keep || entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			log.Printf("[DEBUG] Unable to prune state cache entry (workspace=%s, entry=%s): %s\n", workspaceUid, name, err)
+		}
+	}
+}
+
+func (c StateCache) entryPath(workspaceUid string, serial int64) string {
+	return filepath.Join(c.Dir, workspaceUid, strconv.FormatInt(serial, 10)+".json")
+}
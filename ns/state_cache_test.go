@@ -0,0 +1,98 @@
+package ns
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateCache(t *testing.T) {
+	uid := "2f1c9a52-7c1b-4bb2-9f34-6e1f3f0d8d11"
+	typ := cty.String
+	stateFile := &StateFile{
+		Version:          4,
+		TerraformVersion: "1.5.7",
+		Serial:           3,
+		Lineage:          "5d3c5a0f-6b0e-4a64-9f4a-0c4bfe2f7f10",
+		Outputs: Outputs{
+			"vpc_id": Output{Type: &typ, Value: json.RawMessage(`"vpc-123"`)},
+		},
+	}
+
+	t.Run("returns cached state file for the same state version", func(t *testing.T) {
+		cache := StateCache{Dir: t.TempDir()}
+		require.NoError(t, cache.Put(uid, "sv-1", stateFile))
+		got := cache.Get(uid, "sv-1", 3)
+		if assert.NotNil(t, got) {
+			assert.Equal(t, stateFile.Lineage, got.Lineage)
+			assert.Equal(t, stateFile.Serial, got.Serial)
+			assert.Equal(t, json.RawMessage(`"vpc-123"`), got.Outputs["vpc_id"].Value)
+			assert.True(t, got.Outputs["vpc_id"].Type.Equals(cty.String))
+		}
+	})
+
+	t.Run("misses when the state version changed", func(t *testing.T) {
+		cache := StateCache{Dir: t.TempDir()}
+		require.NoError(t, cache.Put(uid, "sv-1", stateFile))
+		assert.Nil(t, cache.Get(uid, "sv-2", 3), "different state version")
+		assert.Nil(t, cache.Get(uid, "sv-1", 4), "different serial")
+	})
+
+	t.Run("keeps only the latest entry", func(t *testing.T) {
+		cache := StateCache{Dir: t.TempDir()}
+		require.NoError(t, cache.Put(uid, "sv-1", stateFile))
+		next := *stateFile
+		next.Serial = 4
+		require.NoError(t, cache.Put(uid, "sv-2", &next))
+		assert.Nil(t, cache.Get(uid, "sv-1", 3), "older serial is pruned")
+		assert.NotNil(t, cache.Get(uid, "sv-2", 4))
+	})
+
+	t.Run("ignores the cache in git", func(t *testing.T) {
+		cache := StateCache{Dir: filepath.Join(t.TempDir(), "cache")}
+		require.NoError(t, cache.Put(uid, "sv-1", stateFile))
+		raw, err := os.ReadFile(filepath.Join(cache.Dir, ".gitignore"))
+		require.NoError(t, err)
+		assert.Contains(t, string(raw), "\n*\n")
+		info, err := os.Stat(cache.entryPath(uid, stateFile.Serial))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "entries may contain sensitive outputs")
+	})
+
+	t.Run("ignores corrupt entries", func(t *testing.T) {
+		cache := StateCache{Dir: t.TempDir()}
+		require.NoError(t, os.MkdirAll(filepath.Join(cache.Dir, uid), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(cache.Dir, uid, "3.json"), []byte(`{"state_version_id":`), 0644))
+		assert.Nil(t, cache.Get(uid, "sv-1", 3))
+	})
+
+	t.Run("supports concurrent writers", func(t *testing.T) {
+		cache := StateCache{Dir: t.TempDir()}
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, cache.Put(uid, "sv-1", stateFile))
+				if got := cache.Get(uid, "sv-1", 3); got != nil {
+					assert.Equal(t, stateFile.Lineage, got.Lineage)
+				}
+			}()
+		}
+		wg.Wait()
+		entries, err := os.ReadDir(filepath.Join(cache.Dir, uid))
+		require.NoError(t, err)
+		names := make([]string, 0)
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		assert.Equal(t, []string{fmt.Sprintf("%d.json", stateFile.Serial)}, names, "temp files should be cleaned up")
+	})
+}
@@ -1,11 +1,13 @@
 package ns
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"github.com/hashicorp/go-tfe"
 	"log"
+	"time"
+
+	"github.com/hashicorp/go-tfe"
 )
 
 type StateFile struct {
@@ -14,8 +16,15 @@ type StateFile struct {
 	Serial           int64   `json:"serial"`
 	Lineage          string  `json:"lineage"`
 	Outputs          Outputs `json:"outputs"`
+
+	// UpdatedAt is when the state file was last written (i.e. the creation time of the current state version)
+	// This is not part of the state file, it is set by the StateSource and is zero if unknown
+	UpdatedAt time.Time `json:"-"`
 }
 
+// GetStateFile retrieves the current state file of a TFE workspace
+//
+// Deprecated: Use TfeStateSource, which streams the state file instead of loading it into memory
 func GetStateFile(tfeClient *tfe.Client, orgName string, workspaceName string) (*StateFile, error) {
 	log.Printf("[DEBUG] Retrieving state file (org=%s, workspace=%s)\n", orgName, workspaceName)
 
@@ -35,12 +44,33 @@ func GetStateFile(tfeClient *tfe.Client, orgName string, workspaceName string) (
 	if err != nil {
 		return nil, fmt.Errorf(`error downloading state file (org=%s, workspace=%s): %w`, orgName, workspaceName, err)
 	}
-
 	log.Printf("[DEBUG] Retrieved state file (org=%s, workspace=%s): size=%d\n", orgName, workspaceName, len(state))
 
-	var stateFile StateFile
-	if err := json.Unmarshal(state, &stateFile); err != nil {
+	stateFile, err := DecodeStateFile(bytes.NewReader(state), 0)
+	if err != nil {
 		return nil, fmt.Errorf(`error parsing state file (org=%s, workspace=%s): %w`, orgName, workspaceName, err)
 	}
-	return &stateFile, nil
+	stateFile.UpdatedAt = sv.CreatedAt
+	return stateFile, nil
+}
+
+type ErrUnsupportedStateVersion struct {
+	Version int
+}
+
+func (e *ErrUnsupportedStateVersion) Error() string {
+	return fmt.Sprintf("state file version %d is not supported (supported versions: 3, 4)", e.Version)
+}
+
+// UnmarshalJSON parses a state file based on its version
+// Version 4 state files (terraform >= 0.12) contain root-level `outputs`
+// Version 3 state files (terraform < 0.12) contain outputs in `modules[].outputs`
+// Both are normalized so that Outputs contains the root module's outputs
+func (f *StateFile) UnmarshalJSON(data []byte) error {
+	decoded, err := DecodeStateFile(bytes.NewReader(data), 0)
+	if err != nil {
+		return err
+	}
+	*f = *decoded
+	return nil
 }
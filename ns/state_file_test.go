@@ -0,0 +1,95 @@
+package ns
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateFile_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		inputFile   string
+		wantVersion int
+		wantSerial  int64
+		wantValue   tftypes.Value
+	}{
+		{
+			name:        "v3 with root and child module outputs",
+			inputFile:   filepath.Join("test-fixtures", "state-files", "04.json"),
+			wantVersion: 3,
+			wantSerial:  7,
+			wantValue: tftypes.NewValue(tftypes.Object{
+				AttributeTypes: map[string]tftypes.Type{
+					"key1": tftypes.String,
+					"key2": tftypes.List{ElementType: tftypes.String},
+					"key3": tftypes.Map{ElementType: tftypes.String},
+				},
+			}, map[string]tftypes.Value{
+				"key1": tftypes.NewValue(tftypes.String, "value1"),
+				"key2": tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, []tftypes.Value{
+					tftypes.NewValue(tftypes.String, "subnet-1"),
+					tftypes.NewValue(tftypes.String, "subnet-2"),
+				}),
+				"key3": tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, map[string]tftypes.Value{
+					"key1": tftypes.NewValue(tftypes.String, "value1"),
+					"key2": tftypes.NewValue(tftypes.String, "value2"),
+				}),
+			}),
+		},
+		{
+			name:        "v3 with empty list",
+			inputFile:   filepath.Join("test-fixtures", "state-files", "05.json"),
+			wantVersion: 3,
+			wantSerial:  1,
+			wantValue: tftypes.NewValue(tftypes.Object{
+				AttributeTypes: map[string]tftypes.Type{
+					"key1": tftypes.List{ElementType: tftypes.String},
+				},
+			}, map[string]tftypes.Value{
+				"key1": tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, []tftypes.Value{}),
+			}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw, err := os.ReadFile(test.inputFile)
+			require.NoError(t, err, "read input file")
+			var stateFile StateFile
+			require.NoError(t, json.Unmarshal(raw, &stateFile), "unmarshal input file")
+			assert.Equal(t, test.wantVersion, stateFile.Version, "version")
+			assert.Equal(t, test.wantSerial, stateFile.Serial, "serial")
+
+			gotValue, err := stateFile.Outputs.ToProtov5()
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, test.wantValue, gotValue, "result")
+		})
+	}
+}
+
+func TestStateFile_UnmarshalJSON_UnsupportedVersion(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("test-fixtures", "state-files", "06.json"))
+	require.NoError(t, err, "read input file")
+	var stateFile StateFile
+	err = json.Unmarshal(raw, &stateFile)
+	var unsupported *ErrUnsupportedStateVersion
+	if assert.True(t, errors.As(err, &unsupported), "expected ErrUnsupportedStateVersion, got %v", err) {
+		assert.Equal(t, 2, unsupported.Version)
+	}
+}
+
+func TestOutputs_ToProtov5_MissingType(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("test-fixtures", "state-files", "07.json"))
+	require.NoError(t, err, "read input file")
+	var stateFile StateFile
+	require.NoError(t, json.Unmarshal(raw, &stateFile), "unmarshal input file")
+	_, err = stateFile.Outputs.ToProtov5()
+	assert.EqualError(t, err, `output "key1" is missing a type`)
+}
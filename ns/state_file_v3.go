@@ -0,0 +1,124 @@
+package ns
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/go-cty/cty"
+	ctyjson "github.com/hashicorp/go-cty/cty/json"
+)
+
+var rootModulePath = []string{"root"}
+
+// stateFileV3 represents the state file format used by terraform < 0.12
+type stateFileV3 struct {
+	Version          int             `json:"version"`
+	TerraformVersion string          `json:"terraform_version"`
+	Serial           int64           `json:"serial"`
+	Lineage          string          `json:"lineage"`
+	Modules          []moduleStateV3 `json:"modules"`
+}
+
+type moduleStateV3 struct {
+	Path    []string                 `json:"path"`
+	Outputs map[string]outputStateV3 `json:"outputs"`
+}
+
+// outputStateV3 is an output in a v3 state file
+// Type is one of "string", "list", or "map"
+type outputStateV3 struct {
+	Sensitive bool            `json:"sensitive"`
+	Type      string          `json:"type"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// Normalize converts a v3 state file into a StateFile
+// Only outputs from the root module are included since those are the only outputs exposed as remote state
+func (s stateFileV3) Normalize() (*StateFile, error) {
+	result := &StateFile{
+		Version:          s.Version,
+		TerraformVersion: s.TerraformVersion,
+		Serial:           s.Serial,
+		Lineage:          s.Lineage,
+		Outputs:          Outputs{},
+	}
+	for _, module := range s.Modules {
+		if !isRootModulePath(module.Path) {
+			continue
+		}
+		for name, output := range module.Outputs {
+			typ, err := output.ctyType()
+			if err != nil {
+				return nil, fmt.Errorf("error reading output %q: %w", name, err)
+			}
+			result.Outputs[name] = Output{Type: &typ, Value: output.Value, Sensitive: output.Sensitive}
+		}
+	}
+	return result, nil
+}
+
+// ctyType determines the cty.Type for a v3 output
+// v3 state files only record "string", "list", or "map"; element types are implied from the value
+func (o outputStateV3) ctyType() (cty.Type, error) {
+	switch o.Type {
+	case "string":
+		return cty.String, nil
+	case "list":
+		implied, err := ctyjson.ImpliedType(o.Value)
+		if err != nil {
+			return cty.NilType, err
+		}
+		if !implied.IsTupleType() {
+			return cty.NilType, fmt.Errorf("expected list value, got %s", implied.FriendlyName())
+		}
+		if elemType, ok := commonElementType(implied.TupleElementTypes()); ok {
+			return cty.List(elemType), nil
+		}
+		return implied, nil
+	case "map":
+		implied, err := ctyjson.ImpliedType(o.Value)
+		if err != nil {
+			return cty.NilType, err
+		}
+		if !implied.IsObjectType() {
+			return cty.NilType, fmt.Errorf("expected map value, got %s", implied.FriendlyName())
+		}
+		elemTypes := make([]cty.Type, 0, len(implied.AttributeTypes()))
+		for _, attrType := range implied.AttributeTypes() {
+			elemTypes = append(elemTypes, attrType)
+		}
+		if elemType, ok := commonElementType(elemTypes); ok {
+			return cty.Map(elemType), nil
+		}
+		return implied, nil
+	}
+	return cty.NilType, fmt.Errorf("unknown output type %q", o.Type)
+}
+
+// commonElementType returns the element type shared by all types
+// v3 state files typically contain lists and maps of strings, so we default to cty.String when empty
+// If the types are heterogeneous, this returns false so the caller can fall back to the implied tuple/object type
+func commonElementType(types []cty.Type) (cty.Type, bool) {
+	if len(types) == 0 {
+		return cty.String, true
+	}
+	first := types[0]
+	for _, cur := range types[1:] {
+		if !cur.Equals(first) {
+			return cty.NilType, false
+		}
+	}
+	return first, true
+}
+
+func isRootModulePath(path []string) bool {
+	if len(path) != len(rootModulePath) {
+		return false
+	}
+	for i := range path {
+		if path[i] != rootModulePath[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,30 @@
+package ns
+
+import (
+	"context"
+	"fmt"
+
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+// StateSource retrieves the terraform state file for a Nullstone workspace
+// TfeStateSource is the default implementation which pulls state from Nullstone's TFE-compatible backend
+// FsStateSource allows for reading state files from the local filesystem (e.g. local module development)
+type StateSource interface {
+	GetStateFile(ctx context.Context, workspace types.Workspace) (*StateFile, error)
+}
+
+// ErrNoStateFile occurs when a workspace does not have a state file yet
+// Typically, this means the workspace has never been applied
+type ErrNoStateFile struct {
+	WorkspaceId string
+	// Dir is set if the state file was searched for in a local directory
+	Dir string
+}
+
+func (e *ErrNoStateFile) Error() string {
+	if e.Dir != "" {
+		return fmt.Sprintf("no %s found for workspace %s in %s", StateFilename, e.WorkspaceId, e.Dir)
+	}
+	return fmt.Sprintf("workspace %s has no state file, it has likely never been applied", e.WorkspaceId)
+}
@@ -1,11 +1,16 @@
 package ns
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+
 	"github.com/hashicorp/go-tfe"
 	"gopkg.in/nullstone-io/go-api-client.v0"
-	"gopkg.in/nullstone-io/go-api-client.v0/auth"
 )
 
+const TfeTokenEnvVar = "TFE_TOKEN"
+
 func NewTfeConfig(apiConfig api.Config) *tfe.Config {
 	cfg := tfe.DefaultConfig()
 	if apiConfig.BaseAddress != "" {
@@ -13,11 +18,49 @@ func NewTfeConfig(apiConfig api.Config) *tfe.Config {
 	}
 	cfg.BasePath = "/terraform/v2/"
 	// By default, cfg.Token loads TFE_TOKEN env var
-	// Fall back to TFE_TOKEN if api key is missing
-	if apiConfig.AccessTokenSource != nil {
-		if rats, ok := apiConfig.AccessTokenSource.(auth.RawAccessTokenSource); ok {
-			cfg.Token = rats.AccessToken
-		}
-	}
+	// If the nullstone api config has an access token source, ConfigureTfeAuth will use that instead
 	return cfg
 }
+
+// ConfigureTfeAuth configures cfg to authenticate every TFE request with a token from apiConfig.AccessTokenSource
+// The token is retrieved from the token source on every request, which allows short-lived tokens (e.g. JWTs) to refresh
+// If apiConfig has no access token source, cfg is left unchanged and relies on TFE_TOKEN
+func ConfigureTfeAuth(ctx context.Context, cfg *tfe.Config, apiConfig api.Config) error {
+	if apiConfig.AccessTokenSource == nil {
+		return nil
+	}
+
+	// tfe.NewClient requires a token, so we acquire one up front
+	// This also verifies that the token source is able to produce a token before we make any requests
+	token, err := apiConfig.AccessTokenSource.GetAccessToken(ctx, apiConfig.OrgName)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve access token for TFE: %w", err)
+	}
+	cfg.Token = token
+
+	var base http.RoundTripper = http.DefaultTransport
+	if cfg.HTTPClient != nil && cfg.HTTPClient.Transport != nil {
+		base = cfg.HTTPClient.Transport
+	}
+	httpClient := &http.Client{}
+	if cfg.HTTPClient != nil {
+		*httpClient = *cfg.HTTPClient
+	}
+	httpClient.Transport = &accessTokenTransport{Base: base, ApiConfig: apiConfig}
+	cfg.HTTPClient = httpClient
+	return nil
+}
+
+// accessTokenTransport replaces the Authorization header on every request with a token from the nullstone access token source
+type accessTokenTransport struct {
+	Base      http.RoundTripper
+	ApiConfig api.Config
+}
+
+func (t *accessTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if err := t.ApiConfig.AddAuthorizationHeader(req.Context(), req.Header); err != nil {
+		return nil, err
+	}
+	return t.Base.RoundTrip(req)
+}
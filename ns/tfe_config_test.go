@@ -0,0 +1,62 @@
+package ns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/nullstone-io/go-api-client.v0"
+)
+
+// rotatingTokenSource returns a new token for every call to simulate short-lived tokens that refresh
+type rotatingTokenSource struct {
+	sync.Mutex
+	calls    int
+	orgNames []string
+}
+
+func (s *rotatingTokenSource) GetAccessToken(ctx context.Context, orgName string) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.calls++
+	s.orgNames = append(s.orgNames, orgName)
+	return fmt.Sprintf("token-%d", s.calls), nil
+}
+
+func TestConfigureTfeAuth(t *testing.T) {
+	t.Run("authenticates every request with the access token source", func(t *testing.T) {
+		gotAuth := make([]string, 0)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotAuth = append(gotAuth, r.Header.Get("Authorization"))
+		}))
+		defer server.Close()
+
+		source := &rotatingTokenSource{}
+		apiConfig := api.Config{BaseAddress: server.URL, OrgName: "org0", AccessTokenSource: source}
+		cfg := NewTfeConfig(apiConfig)
+		require.NoError(t, ConfigureTfeAuth(context.Background(), cfg, apiConfig))
+		assert.Equal(t, "token-1", cfg.Token, "initial token")
+
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			req.Header.Set("Authorization", "Bearer "+cfg.Token)
+			res, err := cfg.HTTPClient.Do(req)
+			require.NoError(t, err)
+			res.Body.Close()
+		}
+		assert.Equal(t, []string{"Bearer token-2", "Bearer token-3"}, gotAuth)
+		assert.Equal(t, []string{"org0", "org0", "org0"}, source.orgNames)
+	})
+
+	t.Run("falls back to TFE_TOKEN without an access token source", func(t *testing.T) {
+		t.Setenv(TfeTokenEnvVar, "tfe-token")
+		cfg := NewTfeConfig(api.Config{})
+		require.NoError(t, ConfigureTfeAuth(context.Background(), cfg, api.Config{}))
+		assert.Equal(t, "tfe-token", cfg.Token)
+	})
+}
@@ -0,0 +1,130 @@
+package ns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/hashicorp/go-tfe"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+var _ StateSource = TfeStateSource{}
+
+// TfeStateSource retrieves state files through Nullstone's TFE-compatible state backend
+// TFE workspaces are named by the Nullstone workspace uid
+// State files are streamed from the backend so that only the outputs are held in memory
+type TfeStateSource struct {
+	Client  *tfe.Client
+	Config  *tfe.Config
+	OrgName string
+	// MaxSize limits the size of a downloaded state file
+	// If 0, DefaultMaxStateFileSize is used
+	MaxSize int64
+	// Cache is used to skip downloading state files that have not changed since the last download
+	// If nil, state files are always downloaded
+	Cache *StateCache
+}
+
+func (s TfeStateSource) GetStateFile(ctx context.Context, workspace types.Workspace) (*StateFile, error) {
+	orgName, workspaceName := s.OrgName, workspace.Uid.String()
+	log.Printf("[DEBUG] Retrieving state file (org=%s, workspace=%s)\n", orgName, workspaceName)
+
+	tfeWorkspace, err := s.Client.Workspaces.Read(ctx, orgName, workspaceName)
+	if errors.Is(err, tfe.ErrResourceNotFound) {
+		// The state backend creates the workspace on the first apply
+		return nil, &ErrNoStateFile{WorkspaceId: workspaceTargetOf(workspace).Id()}
+	} else if err != nil {
+		return nil, fmt.Errorf(`error reading workspace (org=%s, workspace=%s): %w`, orgName, workspaceName, err)
+	}
+	log.Printf("[DEBUG] Found workspace (org=%s, workspace=%s), workspace id=%s", orgName, workspaceName, tfeWorkspace.ID)
+
+	sv, err := s.Client.StateVersions.Current(ctx, tfeWorkspace.ID)
+	if errors.Is(err, tfe.ErrResourceNotFound) {
+		return nil, &ErrNoStateFile{WorkspaceId: workspaceTargetOf(workspace).Id()}
+	} else if err != nil {
+		return nil, fmt.Errorf(`error reading current state version (org=%s, workspace=%s): %w`, orgName, workspaceName, err)
+	}
+
+	if s.Cache != nil {
+		if cached := s.Cache.Get(workspaceName, sv.ID, sv.Serial); cached != nil {
+			log.Printf("[DEBUG] Using cached state file (org=%s, workspace=%s): serial=%d\n", orgName, workspaceName, cached.Serial)
+			cached.UpdatedAt = sv.CreatedAt
+			return cached, nil
+		}
+	}
+
+	log.Printf("[DEBUG] Downloading state file (org=%s, workspace=%s) from %s", orgName, workspaceName, sv.DownloadURL)
+	stateFile, err := s.download(ctx, sv.DownloadURL)
+	if err != nil {
+		return nil, fmt.Errorf(`error downloading state file (org=%s, workspace=%s): %w`, orgName, workspaceName, err)
+	}
+	log.Printf("[DEBUG] Retrieved state file (org=%s, workspace=%s): serial=%d\n", orgName, workspaceName, stateFile.Serial)
+	stateFile.UpdatedAt = sv.CreatedAt
+
+	if s.Cache != nil {
+		if err := s.Cache.Put(workspaceName, sv.ID, stateFile); err != nil {
+			log.Printf("[WARN] Unable to cache state file (org=%s, workspace=%s): %s\n", orgName, workspaceName, err)
+		}
+	}
+	return stateFile, nil
+}
+
+func (s TfeStateSource) maxSize() int64 {
+	if s.MaxSize > 0 {
+		return s.MaxSize
+	}
+	return DefaultMaxStateFileSize
+}
+
+// download streams the state file at downloadUrl through DecodeStateFile
+// tfe.Client's StateVersions.Download reads the entire response into memory, so we issue the request ourselves
+func (s TfeStateSource) download(ctx context.Context, downloadUrl string) (*StateFile, error) {
+	baseUrl, err := url.Parse(s.Config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address: %w", err)
+	}
+	baseUrl.Path = s.Config.BasePath
+	u, err := baseUrl.Parse(downloadUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid download url: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range s.Config.Headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Config.Token)
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := s.Config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusUnauthorized:
+		return nil, tfe.ErrUnauthorized
+	case res.StatusCode == http.StatusNotFound:
+		return nil, tfe.ErrResourceNotFound
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		return nil, fmt.Errorf("unexpected response status: %s", res.Status)
+	}
+
+	maxSize := s.maxSize()
+	if res.ContentLength > maxSize {
+		return nil, &ErrStateFileTooLarge{MaxSize: maxSize}
+	}
+	return DecodeStateFile(res.Body, maxSize)
+}
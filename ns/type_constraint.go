@@ -0,0 +1,65 @@
+package ns
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+)
+
+// ParseTypeConstraint parses a terraform-style type constraint (e.g. `string`, `list(string)`, `map(any)`)
+// Supported constraints are `any`, `string`, `number`, `bool`, and `list(...)`, `set(...)`, `map(...)` of a supported constraint
+// `list`, `set`, and `map` without an element type are equivalent to `list(any)`, `set(any)`, and `map(any)`
+func ParseTypeConstraint(s string) (cty.Type, error) {
+	expr := strings.ReplaceAll(s, " ", "")
+	t, rest, err := parseTypeConstraint(expr)
+	if err != nil {
+		return cty.NilType, fmt.Errorf("invalid type constraint %q: %w", s, err)
+	}
+	if rest != "" {
+		return cty.NilType, fmt.Errorf("invalid type constraint %q: unexpected %q", s, rest)
+	}
+	return t, nil
+}
+
+func parseTypeConstraint(expr string) (cty.Type, string, error) {
+	end := strings.IndexAny(expr, "()")
+	if end < 0 {
+		end = len(expr)
+	}
+	keyword, rest := expr[:end], expr[end:]
+
+	switch keyword {
+	case "any":
+		return cty.DynamicPseudoType, rest, nil
+	case "string":
+		return cty.String, rest, nil
+	case "number":
+		return cty.Number, rest, nil
+	case "bool":
+		return cty.Bool, rest, nil
+	case "list", "set", "map":
+		elem := cty.DynamicPseudoType
+		if strings.HasPrefix(rest, "(") {
+			var err error
+			if elem, rest, err = parseTypeConstraint(rest[1:]); err != nil {
+				return cty.NilType, "", err
+			}
+			if !strings.HasPrefix(rest, ")") {
+				return cty.NilType, "", fmt.Errorf("missing closing parenthesis for %s", keyword)
+			}
+			rest = rest[1:]
+		}
+		switch keyword {
+		case "list":
+			return cty.List(elem), rest, nil
+		case "set":
+			return cty.Set(elem), rest, nil
+		default:
+			return cty.Map(elem), rest, nil
+		}
+	case "":
+		return cty.NilType, "", fmt.Errorf("missing type")
+	}
+	return cty.NilType, "", fmt.Errorf("unsupported type %q", keyword)
+}
@@ -0,0 +1,42 @@
+package ns
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTypeConstraint(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    cty.Type
+		wantErr string
+	}{
+		{input: "any", want: cty.DynamicPseudoType},
+		{input: "string", want: cty.String},
+		{input: "number", want: cty.Number},
+		{input: "bool", want: cty.Bool},
+		{input: "list", want: cty.List(cty.DynamicPseudoType)},
+		{input: "list(string)", want: cty.List(cty.String)},
+		{input: "set(number)", want: cty.Set(cty.Number)},
+		{input: "map( list(string) )", want: cty.Map(cty.List(cty.String))},
+		{input: "", wantErr: `invalid type constraint "": missing type`},
+		{input: "object", wantErr: `invalid type constraint "object": unsupported type "object"`},
+		{input: "list(string", wantErr: `invalid type constraint "list(string": missing closing parenthesis for list`},
+		{input: "string)", wantErr: `invalid type constraint "string)": unexpected ")"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got, err := ParseTypeConstraint(test.input)
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, test.want.Equals(got), "expected %s, got %s", test.want.FriendlyName(), got.FriendlyName())
+		})
+	}
+}
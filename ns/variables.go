@@ -0,0 +1,48 @@
+package ns
+
+import (
+	"encoding/json"
+	"fmt"
+
+	ctyjson "github.com/hashicorp/go-cty/cty/json"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+// VariablesToProtov5 converts the values of variables into a single object value
+// Sensitive and redacted variables are excluded because their values are not available
+// A variable without a value is converted to a null string
+func VariablesToProtov5(variables types.Variables) (tftypes.Value, error) {
+	objType := tftypes.Object{AttributeTypes: map[string]tftypes.Type{}}
+	all := map[string]tftypes.Value{}
+
+	for name, variable := range variables {
+		if variable.Sensitive || variable.Redacted {
+			continue
+		}
+		if variable.Value == nil {
+			objType.AttributeTypes[name] = tftypes.String
+			all[name] = tftypes.NewValue(tftypes.String, nil)
+			continue
+		}
+		raw, err := json.Marshal(variable.Value)
+		if err != nil {
+			return tftypes.Value{}, fmt.Errorf("error reading variable %q: %w", name, err)
+		}
+		ctyType, err := ctyjson.ImpliedType(raw)
+		if err != nil {
+			return tftypes.Value{}, fmt.Errorf("error reading variable %q: %w", name, err)
+		}
+		ctyVal, err := ctyjson.Unmarshal(raw, ctyType)
+		if err != nil {
+			return tftypes.Value{}, fmt.Errorf("error reading variable %q: %w", name, err)
+		}
+		val, err := TftypeValueFromCtyValue(ctyVal)
+		if err != nil {
+			return tftypes.Value{}, fmt.Errorf("error converting variable %q: %w", name, err)
+		}
+		objType.AttributeTypes[name] = val.Type()
+		all[name] = val
+	}
+	return tftypes.NewValue(objType, all), nil
+}
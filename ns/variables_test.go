@@ -0,0 +1,44 @@
+package ns
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/nullstone-io/module/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/nullstone-io/go-api-client.v0/types"
+)
+
+func TestVariablesToProtov5(t *testing.T) {
+	variables := types.Variables{
+		"service_name": {Value: "api"},
+		"port":         {Value: 8080},
+		"enabled":      {Value: true},
+		"tags":         {Value: map[string]interface{}{"team": "core"}},
+		"unset":        {},
+		"api_key":      {Variable: config.Variable{Sensitive: true}, Value: "secret"},
+		"redacted":     {Value: "****", Redacted: true},
+	}
+
+	got, err := VariablesToProtov5(variables)
+	require.NoError(t, err)
+
+	tagsType := tftypes.Object{AttributeTypes: map[string]tftypes.Type{"team": tftypes.String}}
+	wantType := tftypes.Object{AttributeTypes: map[string]tftypes.Type{
+		"service_name": tftypes.String,
+		"port":         tftypes.Number,
+		"enabled":      tftypes.Bool,
+		"tags":         tagsType,
+		"unset":        tftypes.String,
+	}}
+	want := tftypes.NewValue(wantType, map[string]tftypes.Value{
+		"service_name": tftypes.NewValue(tftypes.String, "api"),
+		"port":         tftypes.NewValue(tftypes.Number, big.NewFloat(8080)),
+		"enabled":      tftypes.NewValue(tftypes.Bool, true),
+		"tags":         tftypes.NewValue(tagsType, map[string]tftypes.Value{"team": tftypes.NewValue(tftypes.String, "core")}),
+		"unset":        tftypes.NewValue(tftypes.String, nil),
+	})
+	assert.True(t, want.Equal(got), "expected %s, got %s", want, got)
+}